@@ -0,0 +1,187 @@
+package v1_1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Acknowledgement is a note that a check is known to be failing, e.g. while its dependency is
+// being fixed
+type Acknowledgement struct {
+	Message string    `json:"message"`
+	Author  string    `json:"author,omitempty"`
+	At      time.Time `json:"at"`
+	// Expires is when the acknowledgement is cleared by itself, it is kept until cleared when zero
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+func (a Acknowledgement) String() string {
+	text := a.Message
+	if a.Author != "" {
+		text += " - " + a.Author
+	}
+	if !a.Expires.IsZero() {
+		text += ", until " + a.Expires.Format(time.RFC3339)
+	}
+	return text
+}
+
+// AckHealthCheck sets the Ack of the results of acknowledged checks. Acknowledged checks still
+// fail, use AckPolicy to leave them out of the overall status and severity.
+type AckHealthCheck struct {
+	HC
+
+	mu   sync.Mutex
+	acks map[string]Acknowledgement
+}
+
+func NewAckHealthCheck(hc HC) *AckHealthCheck {
+	return &AckHealthCheck{HC: hc, acks: make(map[string]Acknowledgement)}
+}
+
+// Acknowledge acknowledges the check with the given ID, replacing any earlier acknowledgement.
+// At is set to now when it is zero.
+func (ah *AckHealthCheck) Acknowledge(id string, ack Acknowledgement) error {
+	if ack.Message == "" {
+		return fmt.Errorf("Cannot acknowledge check %s without a message", id)
+	}
+	now := ah.clock().Now()
+	if !ack.Expires.IsZero() && !ack.Expires.After(now) {
+		return fmt.Errorf("Cannot acknowledge check %s until %v, which has already passed", id, ack.Expires.Format(time.RFC3339))
+	}
+	if ack.At.IsZero() {
+		ack.At = now
+	}
+	ah.mu.Lock()
+	defer ah.mu.Unlock()
+	ah.acks[id] = ack
+	return nil
+}
+
+// ClearAck clears the acknowledgement of the check with the given ID, returning whether there was one
+func (ah *AckHealthCheck) ClearAck(id string) bool {
+	ah.mu.Lock()
+	defer ah.mu.Unlock()
+	_, ok := ah.current(id, ah.clock().Now())
+	delete(ah.acks, id)
+	return ok
+}
+
+// Ack returns the acknowledgement of the check with the given ID, if it has one
+func (ah *AckHealthCheck) Ack(id string) (Acknowledgement, bool) {
+	ah.mu.Lock()
+	defer ah.mu.Unlock()
+	return ah.current(id, ah.clock().Now())
+}
+
+// current returns the acknowledgement of the check unless it has expired, which is then removed
+func (ah *AckHealthCheck) current(id string, now time.Time) (Acknowledgement, bool) {
+	ack, ok := ah.acks[id]
+	if ok && !ack.Expires.IsZero() && !ack.Expires.After(now) {
+		delete(ah.acks, id)
+		return Acknowledgement{}, false
+	}
+	return ack, ok
+}
+
+func (ah *AckHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	ah.HC.doChecks(ctx, result)
+
+	now := clockFrom(ctx).Now()
+	ah.mu.Lock()
+	defer ah.mu.Unlock()
+	for i := range result.Checks {
+		check := &result.Checks[i]
+		if check.ID == "" {
+			continue
+		}
+		if ack, ok := ah.current(check.ID, now); ok {
+			check.Ack = ack.String()
+		}
+	}
+}
+
+// AckPolicy leaves acknowledged checks out of the overall status and severity, which are then
+// worked out by Policy, DefaultPolicy when it is nil
+type AckPolicy struct {
+	Policy Policy
+}
+
+func (ap AckPolicy) Status(result *HealthResult) bool {
+	unacked := withoutAcked(result)
+	return ap.policy().Status(&unacked)
+}
+
+func (ap AckPolicy) Severity(result *HealthResult) uint8 {
+	unacked := withoutAcked(result)
+	return ap.policy().Severity(&unacked)
+}
+
+func (ap AckPolicy) policy() Policy {
+	if ap.Policy == nil {
+		return DefaultPolicy{}
+	}
+	return ap.Policy
+}
+
+// withoutAcked returns a copy of result without the acknowledged checks
+func withoutAcked(result *HealthResult) HealthResult {
+	unacked := *result
+	unacked.Checks = make([]CheckResult, 0, len(result.Checks))
+	for _, check := range result.Checks {
+		if check.Ack == "" {
+			unacked.Checks = append(unacked.Checks, check)
+		}
+	}
+	return unacked
+}
+
+// AckHandler serves the acknowledgement of the check whose ID is in the path, taken as for
+// SingleCheckHandler, e.g. registered as "/__health/{checkID}/ack". PUT or POST a JSON
+// Acknowledgement to acknowledge the check, DELETE to clear it and GET to fetch it.
+func AckHandler(ah *AckHealthCheck) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := checkIDFrom(r)
+		switch r.Method {
+		case http.MethodGet:
+			ack, ok := ah.Ack(id)
+			if !ok {
+				writeJSON(w, http.StatusNotFound, ErrorMessage{fmt.Sprintf("Check %s is not acknowledged", id)})
+				return
+			}
+			writeJSON(w, http.StatusOK, ack)
+		case http.MethodPut, http.MethodPost:
+			var ack Acknowledgement
+			if err := json.NewDecoder(r.Body).Decode(&ack); err != nil {
+				writeJSON(w, http.StatusBadRequest, ErrorMessage{fmt.Sprintf("Failed to decode acknowledgement, error was: %v", err)})
+				return
+			}
+			if err := ah.Acknowledge(id, ack); err != nil {
+				writeJSON(w, http.StatusBadRequest, ErrorMessage{err.Error()})
+				return
+			}
+			ack, _ = ah.Ack(id)
+			writeJSON(w, http.StatusOK, ack)
+		case http.MethodDelete:
+			if !ah.ClearAck(id) {
+				writeJSON(w, http.StatusNotFound, ErrorMessage{fmt.Sprintf("Check %s is not acknowledged", id)})
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete}, ", "))
+			writeJSON(w, http.StatusMethodNotAllowed, ErrorMessage{fmt.Sprintf("Method %s is not allowed", r.Method)})
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,80 @@
+package v1_1
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// CircuitBreaker stops a check's checker from being called while the dependency is known to be
+// down. After FailureThreshold failures in a row the circuit opens and the check fails fast with
+// the last error. Once OpenDuration has passed a single trial run is let through (half-open), which
+// closes the circuit again if it passes and reopens it if it fails.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	lastErr  string
+}
+
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{FailureThreshold: failureThreshold, OpenDuration: openDuration}
+}
+
+// State returns one of CircuitClosed, CircuitOpen or CircuitHalfOpen
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.currentState()
+}
+
+func (cb *CircuitBreaker) currentState() string {
+	if cb.state == "" {
+		return CircuitClosed
+	}
+	return cb.state
+}
+
+// allow returns an error when the checker must not be called at the given time
+func (cb *CircuitBreaker) allow(now time.Time) error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	switch cb.currentState() {
+	case CircuitOpen:
+		if now.Sub(cb.openedAt) >= cb.OpenDuration {
+			cb.state = CircuitHalfOpen
+			return nil
+		}
+	case CircuitHalfOpen:
+		// a trial run is already in progress
+	default:
+		return nil
+	}
+	return fmt.Errorf("Circuit breaker is open, last error was: %s", cb.lastErr)
+}
+
+func (cb *CircuitBreaker) record(ok bool, output string, now time.Time) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if ok {
+		cb.state = CircuitClosed
+		cb.failures = 0
+		return
+	}
+	cb.lastErr = output
+	cb.failures++
+	if cb.currentState() == CircuitHalfOpen || cb.failures >= cb.FailureThreshold {
+		cb.state = CircuitOpen
+		cb.openedAt = now
+	}
+}
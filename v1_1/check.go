@@ -1,8 +1,11 @@
 package v1_1
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log"
+	"runtime/debug"
 	"strings"
 	"time"
 )
@@ -15,39 +18,70 @@ type Check struct {
 	TechnicalSummary string
 	PanicGuide       string
 	Checker          func() (string, error)
-	Timeout          time.Duration
+	// ContextChecker takes precedence over Checker when set. Its context is cancelled once the
+	// check times out or the health check request is abandoned, so it can stop early.
+	ContextChecker func(ctx context.Context) (string, error)
+	Timeout        time.Duration
+	// Retry is used to try the check again when it fails, it is only tried once when it is nil
+	Retry *RetryPolicy
+	// Tags group checks, so that a subset of them can be run with a CheckFilter
+	Tags []string
+	// CircuitBreaker stops the checker being called while its dependency is known to be down. The
+	// same breaker has to be kept for every run of the check.
+	CircuitBreaker *CircuitBreaker
+	// DependsOn lists the IDs of checks which have to pass before this one is run, otherwise it is
+	// skipped. IDs of checks which are not run alongside this one are ignored.
+	DependsOn []string
+	// Interval is how often a ScheduledHealthCheck runs this check, it is ignored by the other HCs.
+	Interval time.Duration
 }
 
-func (ch *Check) runChecker() (result CheckResult) {
+func (ch *Check) runChecker(ctx context.Context) (result CheckResult) {
+	clock := clockFrom(ctx)
+	if cb := ch.CircuitBreaker; cb != nil {
+		if err := cb.allow(clock.Now()); err != nil {
+			result = ch.newResult()
+			result.LastUpdated = clock.Now()
+			result.CheckOutput = err.Error()
+			result.CircuitState = cb.State()
+			return
+		}
+		// Deferred before the panic recovery so that it runs after it and sees the final result
+		defer func() {
+			cb.record(result.Ok, result.CheckOutput, clock.Now())
+			result.CircuitState = cb.State()
+		}()
+	}
 
 	// Any panics hit during checking should cause the check to fail
 	defer func() {
 		if rec := recover(); rec != nil {
+			p := newCheckPanic(rec)
 			result.Ok = false
-			switch t := rec.(type) {
-				case string:
-					result.CheckOutput = t
-				case error:
-					result.CheckOutput = t.Error()
-				default:
-					result.CheckOutput = "Unknown error returned during check"
-			}
+			result.CheckOutput = p.Error()
+			result.PanicStack = p.stack
 		}
+		if result.PanicStack != "" {
+			log.Printf("Check %q panicked: %s\n%s", ch.ID, result.CheckOutput, result.PanicStack)
+		}
+		result.Duration = clock.Now().Sub(result.StartedAt)
 	}()
-	result = CheckResult{
-		ID:               ch.ID,
-		Name:             ch.Name,
-		Severity:         ch.Severity,
-		BusinessImpact:   ch.BusinessImpact,
-		TechnicalSummary: ch.TechnicalSummary,
-		PanicGuide:       ch.PanicGuide,
-		PanicGuideIsLink: strings.HasPrefix(ch.PanicGuide, "http"),
-		LastUpdated:      time.Now(),
+	result = ch.newResult()
+	result.LastUpdated = clock.Now()
+	result.StartedAt = result.LastUpdated
+	result.Timeout = ch.Timeout
+	attempts := &attemptLog{}
+	out, err := ch.check(ctx, attempts)
+	if ch.Retry != nil {
+		attempts.writeTo(&result)
 	}
-	out, err := ch.check()
 	if err != nil {
 		result.Ok = false
 		result.CheckOutput = err.Error()
+		var p *checkPanic
+		if errors.As(err, &p) {
+			result.PanicStack = p.stack
+		}
 	} else {
 		result.Ok = true
 		result.CheckOutput = out
@@ -55,40 +89,118 @@ func (ch *Check) runChecker() (result CheckResult) {
 	return
 }
 
-func (ch *Check) check() (string, error) {
+func (ch *Check) newResult() CheckResult {
+	return CheckResult{
+		ID:               ch.ID,
+		Name:             ch.Name,
+		Severity:         ch.Severity,
+		BusinessImpact:   ch.BusinessImpact,
+		TechnicalSummary: ch.TechnicalSummary,
+		PanicGuide:       ch.PanicGuide,
+		PanicGuideIsLink: strings.HasPrefix(ch.PanicGuide, "http"),
+	}
+}
+
+func (ch *Check) skippedResult(ctx context.Context, reason string) CheckResult {
+	result := ch.newResult()
+	result.LastUpdated = clockFrom(ctx).Now()
+	result.Skipped = true
+	result.CheckOutput = "skipped: " + reason
+	return result
+}
+
+func (ch *Check) check(ctx context.Context, attempts *attemptLog) (string, error) {
 	if ch.Timeout != time.Duration(0) {
-		type result struct {
-			out string
-			err error
-		}
-		resultCh := make(chan result)
-		go func() {
-
-			// Any panics hit during checking should cause the check to fail
-			defer func() {
-				var err error
-				if rec := recover(); rec != nil {
-					switch t := rec.(type) {
-					case string:
-						err = errors.New(t)
-					case error:
-						err = t
-					default:
-						err = errors.New("Unknown error")
-					}
-					resultCh <- result{"", err}
-				}
-				return
-			}()
-			out, err := ch.Checker()
-			resultCh <- result{out, err}
+		var cancel context.CancelFunc
+		ctx, cancel = withTimeoutCause(ctx, clockFrom(ctx), ch.Timeout, fmt.Errorf("Timed out after %v second(s)", ch.Timeout.Seconds()))
+		defer cancel()
+	}
+	if ctx.Done() == nil {
+		return ch.callWithRetry(ctx, attempts)
+	}
+
+	type result struct {
+		out string
+		err error
+	}
+	// Buffered so that a checker finishing after we stopped waiting does not block forever
+	resultCh := make(chan result, 1)
+	go func() {
+
+		// Any panics hit during checking should cause the check to fail
+		defer func() {
+			if rec := recover(); rec != nil {
+				resultCh <- result{"", newCheckPanic(rec)}
+			}
 		}()
+		out, err := ch.callWithRetry(ctx, attempts)
+		resultCh <- result{out, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", contextError(ctx)
+	case res := <-resultCh:
+		return res.out, res.err
+	}
+}
+
+func (ch *Check) callWithRetry(ctx context.Context, attempts *attemptLog) (string, error) {
+	for {
+		attempt := attempts.started()
+		out, err := ch.call(ctx)
+		if err == nil || ch.Retry == nil || !ch.Retry.shouldRetry(attempt, err) {
+			return out, err
+		}
+		attempts.retried(err)
+		clock := clockFrom(ctx)
+		backoff := clock.After(ch.Retry.backoff(attempt))
 		select {
-		case <-time.After(ch.Timeout):
-			return "", fmt.Errorf("Timed out after %v second(s)", ch.Timeout.Seconds())
-		case res := <-resultCh:
-			return res.out, res.err
+		case <-ctx.Done():
+			stopTimer(clock, backoff)
+			return "", err
+		case <-backoff:
 		}
 	}
+}
+
+func (ch *Check) call(ctx context.Context) (string, error) {
+	if ch.ContextChecker != nil {
+		return ch.ContextChecker(ctx)
+	}
 	return ch.Checker()
 }
+
+// checkPanic is the error for a panic in a checker, with the stack trace of the panic
+type checkPanic struct {
+	value interface{}
+	stack string
+}
+
+// newCheckPanic has to be called from the deferred function which recovered value
+func newCheckPanic(value interface{}) *checkPanic {
+	return &checkPanic{value, string(debug.Stack())}
+}
+
+func (p *checkPanic) Error() string {
+	switch t := p.value.(type) {
+	case string:
+		return t
+	case error:
+		return t.Error()
+	default:
+		return fmt.Sprintf("%v", t)
+	}
+}
+
+func (p *checkPanic) Unwrap() error {
+	err, _ := p.value.(error)
+	return err
+}
+
+// contextError describes why ctx was cancelled, preferring the cause given by the canceller.
+func contextError(ctx context.Context) error {
+	if cause := context.Cause(ctx); cause != nil && cause != ctx.Err() {
+		return cause
+	}
+	return fmt.Errorf("Check was cancelled: %v", ctx.Err())
+}
@@ -0,0 +1,170 @@
+package v1_1
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time for timeouts, schedules and the times in results. Tests can use a
+// FakeClock, which only moves when told to.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// ClockHealthCheck runs the checks of the wrapped HC with its Clock
+type ClockHealthCheck struct {
+	HC
+	Clock Clock
+}
+
+func NewClockHealthCheck(hc HC, clock Clock) ClockHealthCheck {
+	return ClockHealthCheck{hc, clock}
+}
+
+func (ch ClockHealthCheck) clock() Clock {
+	return ch.Clock
+}
+
+// timerStopper is implemented by clocks which keep track of their timers, so that timers which are
+// no longer waited on can be dropped
+type timerStopper interface {
+	stop(timer <-chan time.Time)
+}
+
+// stopTimer drops timer, which was returned by the After of clock, when nothing waits on it any more
+func stopTimer(clock Clock, timer <-chan time.Time) {
+	if ts, ok := clock.(timerStopper); ok {
+		ts.stop(timer)
+	}
+}
+
+type clockKey struct{}
+
+func withClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+func clockFrom(ctx context.Context) Clock {
+	if clock, ok := ctx.Value(clockKey{}).(Clock); ok {
+		return clock
+	}
+	return systemClock{}
+}
+
+// withTimeoutCause is context.WithTimeoutCause with the timeout measured by clock
+func withTimeoutCause(ctx context.Context, clock Clock, timeout time.Duration, cause error) (context.Context, context.CancelFunc) {
+	if _, ok := clock.(systemClock); ok {
+		return context.WithTimeoutCause(ctx, timeout, cause)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	expired := clock.After(timeout)
+	go func() {
+		select {
+		case <-expired:
+			cancel(cause)
+		case <-ctx.Done():
+			stopTimer(clock, expired)
+		}
+	}()
+	return ctx, func() {
+		cancel(context.Canceled)
+		stopTimer(clock, expired)
+	}
+}
+
+// FakeClock is a Clock for tests which stands still until it is advanced
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	added   chan struct{}
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, added: make(chan struct{}, 1)}
+}
+
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *FakeClock) After(d time.Duration) <-chan time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- fc.now
+		return ch
+	}
+	fc.waiters = append(fc.waiters, fakeWaiter{fc.now.Add(d), ch})
+	select {
+	case fc.added <- struct{}{}:
+	default:
+	}
+	return ch
+}
+
+// Advance moves the clock on by d, firing the timers which are due by then in order
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = fc.now.Add(d)
+	sort.SliceStable(fc.waiters, func(i, j int) bool {
+		return fc.waiters[i].at.Before(fc.waiters[j].at)
+	})
+	pending := fc.waiters[:0]
+	for _, w := range fc.waiters {
+		if w.at.After(fc.now) {
+			pending = append(pending, w)
+		} else {
+			w.ch <- w.at
+		}
+	}
+	fc.waiters = pending
+}
+
+func (fc *FakeClock) stop(timer <-chan time.Time) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for i, w := range fc.waiters {
+		if (<-chan time.Time)(w.ch) == timer {
+			fc.waiters = append(fc.waiters[:i:i], fc.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// BlockUntil waits until at least n timers are waiting to fire, so that a test knows the code
+// under test has got as far as waiting on the clock before advancing it. Timers nothing waits on
+// any more, such as the timeouts of checks which have finished, are not counted.
+func (fc *FakeClock) BlockUntil(n int) {
+	for {
+		fc.mu.Lock()
+		waiting := len(fc.waiters)
+		fc.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		<-fc.added
+	}
+}
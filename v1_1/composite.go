@@ -0,0 +1,85 @@
+package v1_1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Quorum is how many children of a composite check have to pass for it to pass. The zero value is
+// AllOf.
+type Quorum struct {
+	atLeast int
+	counted bool
+}
+
+// AllOf passes when every child passes
+func AllOf() Quorum {
+	return Quorum{}
+}
+
+// AnyOf passes when one child passes
+func AnyOf() Quorum {
+	return AtLeast(1)
+}
+
+// AtLeast passes when n children pass, n has to be between 1 and the number of children
+func AtLeast(n int) Quorum {
+	return Quorum{atLeast: n, counted: true}
+}
+
+func (q Quorum) required(total int) (int, error) {
+	if !q.counted {
+		return total, nil
+	}
+	if q.atLeast <= 0 || q.atLeast > total {
+		return 0, fmt.Errorf("A quorum of %d is not possible with %d children", q.atLeast, total)
+	}
+	return q.atLeast, nil
+}
+
+// NewCompositeCheck returns check with a checker which runs the children in parallel and passes when
+// enough of them pass for quorum. Its output lists the result of every child. It fails when quorum
+// could never be met by the children, rather than lowering it.
+func NewCompositeCheck(check Check, quorum Quorum, children ...Check) (Check, error) {
+	required, err := quorum.required(len(children))
+	if err != nil {
+		return Check{}, fmt.Errorf("Cannot build composite check %s: %w", check.ID, err)
+	}
+	check.Checker = nil
+	check.ContextChecker = func(ctx context.Context) (string, error) {
+		// The children belong to this check, so they are run whichever checks the run selected, and a
+		// failed child only stops a run failing fast through the result of this check
+		ctx = context.WithValue(context.WithValue(ctx, selectorKey{}, nil), failFastKey{}, nil)
+		results := runChecks(ctx, children, 0)
+
+		passed := 0
+		var lines []string
+		for i, result := range results {
+			status := "failed"
+			if result.Ok {
+				status = "ok"
+				passed++
+			} else if result.Skipped {
+				status = "skipped"
+			}
+			name := children[i].Name
+			if name == "" {
+				name = children[i].ID
+			}
+			line := fmt.Sprintf("[%s] %s", status, name)
+			if result.CheckOutput != "" {
+				line += ": " + result.CheckOutput
+			}
+			lines = append(lines, line)
+		}
+
+		summary := fmt.Sprintf("%d of %d passed, %d needed\n%s", passed, len(children), required, strings.Join(lines, "\n"))
+		if passed < required {
+			return "", errors.New(summary)
+		}
+		return summary, nil
+	}
+	return check, nil
+}
@@ -0,0 +1,55 @@
+package v1_1
+
+// indexChecks maps check IDs to their position, the first check wins when IDs are repeated
+func indexChecks(checks []Check) map[string]int {
+	index := make(map[string]int, len(checks))
+	for i, check := range checks {
+		if _, ok := index[check.ID]; check.ID != "" && !ok {
+			index[check.ID] = i
+		}
+	}
+	return index
+}
+
+// dependencyOrder orders the checks so that each one comes after the checks it depends on, keeping
+// the declaration order where it can. Checks which are in or depend on a cycle are returned separately.
+func dependencyOrder(checks []Check, index map[string]int) (order []int, cyclic []int) {
+	placed := make([]bool, len(checks))
+	for progress := true; progress; {
+		progress = false
+		for i, check := range checks {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for _, dep := range check.DependsOn {
+				if j, ok := index[dep]; ok && !placed[j] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				placed[i] = true
+				order = append(order, i)
+				progress = true
+			}
+		}
+	}
+	for i := range checks {
+		if !placed[i] {
+			cyclic = append(cyclic, i)
+		}
+	}
+	return
+}
+
+// blockingDependency returns the ID of the first dependency of check which did not pass, or an
+// empty string when the check can run. lookup returns the result of a dependency, if there is one.
+func blockingDependency(check Check, lookup func(id string) (CheckResult, bool)) string {
+	for _, dep := range check.DependsOn {
+		if result, ok := lookup(dep); ok && !result.Ok {
+			return dep
+		}
+	}
+	return ""
+}
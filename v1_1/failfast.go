@@ -0,0 +1,76 @@
+package v1_1
+
+import (
+	"context"
+	"errors"
+)
+
+type failFastKey struct{}
+
+// FailFastHealthCheck stops a run as soon as a severity 1 check fails. Checks which are still
+// running are cancelled, and they and the checks which had not started are reported as skipped.
+type FailFastHealthCheck struct {
+	HC
+}
+
+func NewFailFastHealthCheck(hc HC) FailFastHealthCheck {
+	return FailFastHealthCheck{hc}
+}
+
+func (ff FailFastHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	ff.HC.doChecks(context.WithValue(ctx, failFastKey{}, true), result)
+}
+
+// criticalFailure is the cause of a run being stopped by a failed severity 1 check
+type criticalFailure struct {
+	check string
+}
+
+func (cf criticalFailure) Error() string {
+	return cf.check + " failed with severity 1"
+}
+
+// failFast stops the rest of a run once a severity 1 check has failed, it is nil when the run
+// is not failing fast.
+type failFast struct {
+	ctx  context.Context
+	stop context.CancelCauseFunc
+}
+
+// startFailFast returns the context to run the checks with, which is cancelled by the returned
+// failFast on the first critical failure. The cancel function has to be called once the run is over.
+func startFailFast(ctx context.Context) (context.Context, *failFast, context.CancelFunc) {
+	if ctx.Value(failFastKey{}) == nil {
+		return ctx, nil, func() {}
+	}
+	ctx, stop := context.WithCancelCause(ctx)
+	return ctx, &failFast{ctx, stop}, func() { stop(nil) }
+}
+
+// stopped returns why the run was stopped, if it was
+func (ff *failFast) stopped() (string, bool) {
+	var cf criticalFailure
+	if ff == nil || !errors.As(context.Cause(ff.ctx), &cf) {
+		return "", false
+	}
+	return cf.Error(), true
+}
+
+// record stops the run when result is a critical failure, and turns the failures of checks which
+// were cancelled by an earlier critical failure into skipped results.
+func (ff *failFast) record(check *Check, result CheckResult) CheckResult {
+	if ff == nil || result.Ok || result.Skipped {
+		return result
+	}
+	if reason, stopped := ff.stopped(); stopped {
+		return check.skippedResult(ff.ctx, reason)
+	}
+	if result.Severity == 1 {
+		name := check.ID
+		if name == "" {
+			name = check.Name
+		}
+		ff.stop(criticalFailure{name})
+	}
+	return result
+}
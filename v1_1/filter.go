@@ -0,0 +1,107 @@
+package v1_1
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// CheckFilter selects which checks to run by their tags
+type CheckFilter struct {
+	// Tags keeps only the checks which have at least one of these tags, when it is not empty
+	Tags []string `json:"tags,omitempty"`
+	// Exclude leaves out the checks which have any of these tags
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+type filterKey struct{}
+
+type selectorKey struct{}
+
+// WithFilter returns a context which makes RunCheckContext run only the checks matching filter
+func WithFilter(ctx context.Context, filter CheckFilter) context.Context {
+	ctx = context.WithValue(ctx, filterKey{}, &filter)
+	return withSelector(ctx, filter.matches)
+}
+
+// filterFromQuery reads the tag and exclude query parameters, which can be repeated or hold comma
+// separated lists. It returns nil when neither is set.
+func filterFromQuery(query url.Values) *CheckFilter {
+	filter := CheckFilter{Tags: splitQuery(query["tag"]), Exclude: splitQuery(query["exclude"])}
+	if len(filter.Tags) == 0 && len(filter.Exclude) == 0 {
+		return nil
+	}
+	return &filter
+}
+
+func splitQuery(values []string) (split []string) {
+	for _, value := range values {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				split = append(split, v)
+			}
+		}
+	}
+	return
+}
+
+func (f CheckFilter) matches(check Check) bool {
+	if len(f.Tags) != 0 && !check.hasAnyTag(f.Tags) {
+		return false
+	}
+	return !check.hasAnyTag(f.Exclude)
+}
+
+func (f CheckFilter) String() string {
+	var parts []string
+	if len(f.Tags) != 0 {
+		parts = append(parts, "tags: "+strings.Join(f.Tags, ", "))
+	}
+	if len(f.Exclude) != 0 {
+		parts = append(parts, "excluding: "+strings.Join(f.Exclude, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (ch *Check) hasAnyTag(tags []string) bool {
+	for _, tag := range tags {
+		for _, t := range ch.Tags {
+			if t == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withSelector narrows down the checks run with ctx to those selected by selector
+func withSelector(ctx context.Context, selector func(Check) bool) context.Context {
+	if prev, ok := ctx.Value(selectorKey{}).(func(Check) bool); ok {
+		next := selector
+		selector = func(check Check) bool {
+			return prev(check) && next(check)
+		}
+	}
+	return context.WithValue(ctx, selectorKey{}, selector)
+}
+
+func isFiltered(ctx context.Context) bool {
+	return ctx.Value(selectorKey{}) != nil
+}
+
+// selectChecks returns the positions of the checks selected to run with ctx
+func selectChecks(ctx context.Context, checks []Check) []int {
+	selector, _ := ctx.Value(selectorKey{}).(func(Check) bool)
+	selected := make([]int, 0, len(checks))
+	for i, check := range checks {
+		if selector == nil || selector(check) {
+			selected = append(selected, i)
+		}
+	}
+	return selected
+}
+
+func filterFrom(ctx context.Context) *CheckFilter {
+	filter, _ := ctx.Value(filterKey{}).(*CheckFilter)
+	return filter
+}
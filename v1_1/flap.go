@@ -0,0 +1,95 @@
+package v1_1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// FlapSuppressingHealthCheck only reports a check as failing after it failed FailureThreshold times
+// in a row, and as recovered after it passed SuccessThreshold times in a row. A scheduled result is
+// only counted once, so the thresholds count runs of the checks rather than requests when the
+// wrapped HC serves scheduled results, and checks which have not run yet are reported as they are.
+// Checks start out as ok, so a check failing from its first run is reported after FailureThreshold runs.
+type FlapSuppressingHealthCheck struct {
+	HC
+	FailureThreshold int
+	SuccessThreshold int
+
+	mu     sync.Mutex
+	states map[string]*flapState
+}
+
+type flapState struct {
+	ok       bool
+	streak   int
+	run      uint64
+	reported CheckResult
+}
+
+func NewFlapSuppressingHealthCheck(hc HC, failureThreshold, successThreshold int) *FlapSuppressingHealthCheck {
+	return &FlapSuppressingHealthCheck{HC: hc, FailureThreshold: failureThreshold, SuccessThreshold: successThreshold}
+}
+
+func (f *FlapSuppressingHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	f.HC.doChecks(ctx, result)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.states == nil {
+		f.states = make(map[string]*flapState)
+	}
+	for i := range result.Checks {
+		check := &result.Checks[i]
+		// Placeholders for checks which have not run yet are not runs, so they are not counted
+		if check.Skipped || check.LastUpdated.IsZero() {
+			continue
+		}
+		key := resultKey(i, *check)
+		state, seen := f.states[key]
+		if !seen {
+			// Checks start out ok, so that failures while starting up count towards the threshold too
+			state = &flapState{ok: true}
+			f.states[key] = state
+		} else if check.run != 0 && check.run == state.run {
+			*check = state.reported
+			continue
+		}
+		state.run = check.run
+		f.apply(state, check)
+		state.reported = *check
+	}
+}
+
+func (f *FlapSuppressingHealthCheck) apply(state *flapState, check *CheckResult) {
+	if check.Ok == state.ok {
+		state.streak = 0
+		return
+	}
+	threshold := f.FailureThreshold
+	if check.Ok {
+		threshold = f.SuccessThreshold
+	}
+	state.streak++
+	if state.streak >= threshold {
+		state.ok = check.Ok
+		state.streak = 0
+		return
+	}
+
+	if check.Ok {
+		check.CheckOutput = fmt.Sprintf("Passed %d of the %d times in a row needed to recover: %s", state.streak, threshold, check.CheckOutput)
+	} else {
+		check.CheckOutput = fmt.Sprintf("Failed %d of the %d times in a row needed to report a failure: %s", state.streak, threshold, check.CheckOutput)
+	}
+	check.Ok = state.ok
+}
+
+// resultKey identifies the check behind a result between runs, falling back to its position for
+// checks without an ID.
+func resultKey(i int, check CheckResult) string {
+	if check.ID != "" {
+		return check.ID
+	}
+	return fmt.Sprintf("#%d", i)
+}
@@ -1,13 +1,17 @@
 package v1_1
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
 )
 
 type HC interface {
 	initResult(result *HealthResult)
-	doChecks(result *HealthResult)
+	doChecks(ctx context.Context, result *HealthResult)
+	policy() Policy
+	clock() Clock
 }
 
 type HealthCheck struct {
@@ -21,12 +25,25 @@ type HealthCheckSerial struct {
 	HealthCheck
 }
 
+// BoundedHealthCheck runs the checks in parallel, but never more than MaxConcurrency of them at once
+type BoundedHealthCheck struct {
+	HealthCheck
+	MaxConcurrency int
+}
+
 // TimedHealthCheck New type for fail-safe backward compatibility
 type TimedHealthCheck struct {
 	HealthCheck
+	// Timeout is used for checks which do not set Check.Timeout
 	Timeout time.Duration
+	// Deadline bounds the whole run, checks still running when it passes are failed
+	Deadline time.Duration
 }
 
+// FeedbackHealthCheck sends the overall status of each run to its channel, the status is dropped
+// when the channel is not ready to receive it.
+//
+// Deprecated: use ObservedHealthCheck, which supports many subscribers and the full HealthResult.
 type FeedbackHealthCheck struct {
 	HC
 	feedback chan<- bool
@@ -36,15 +53,66 @@ func NewFeedbackHealthCheck(hc HC, fb chan<- bool) FeedbackHealthCheck {
 	return FeedbackHealthCheck{hc, fb}
 }
 
-func RunCheck(hc HC) (result HealthResult) {
+func RunCheck(hc HC) HealthResult {
+	return RunCheckContext(context.Background(), hc)
+}
+
+// RunCheckContext runs the checks of hc, passing ctx on to checkers that accept a context.
+func RunCheckContext(ctx context.Context, hc HC) (result HealthResult) {
+	clock := hc.clock()
+	ctx = withClock(ctx, clock)
+	completed := &runCompletion{}
+	ctx = context.WithValue(ctx, runCompletionKey{}, completed)
 	hc.initResult(&result)
-	hc.doChecks(&result)
+	result.Filter = filterFrom(ctx)
+	result.StartedAt = clock.Now()
+	hc.doChecks(ctx, &result)
+	computeOverall(hc, &result)
+	result.Duration = clock.Now().Sub(result.StartedAt)
+	completed.run(&result)
+	return
+}
+
+type runCompletionKey struct{}
+
+// runCompletion holds the functions to call with the final result of a run, in the order they
+// were added
+type runCompletion struct {
+	mu  sync.Mutex
+	fns []func(*HealthResult)
+}
 
-	result.Ok = ComputeOverallStatus(&result)
+// onRunCompleted calls fn with the result of the run once it is final, which is after the policy
+// of the outermost HC has been applied. Wrappers which report the overall status use it so that
+// they agree with the response. Runs not started by RunCheckContext are final once hc is done.
+func onRunCompleted(ctx context.Context, hc HC, result *HealthResult, fn func(*HealthResult)) {
+	completed, ok := ctx.Value(runCompletionKey{}).(*runCompletion)
+	if !ok {
+		computeOverall(hc, result)
+		fn(result)
+		return
+	}
+	completed.mu.Lock()
+	defer completed.mu.Unlock()
+	completed.fns = append(completed.fns, fn)
+}
+
+func (rc *runCompletion) run(result *HealthResult) {
+	rc.mu.Lock()
+	fns := rc.fns
+	rc.mu.Unlock()
+	for _, fn := range fns {
+		fn(result)
+	}
+}
+
+func computeOverall(hc HC, result *HealthResult) {
+	policy := hc.policy()
+	result.Ok = policy.Status(result)
+	result.Severity = 0
 	if result.Ok == false {
-		result.Severity = ComputeOverallSeverity(&result)
+		result.Severity = policy.Severity(result)
 	}
-	return
 }
 
 func (ch HealthCheck) initResult(result *HealthResult) {
@@ -54,45 +122,128 @@ func (ch HealthCheck) initResult(result *HealthResult) {
 	result.Description = ch.Description
 }
 
-func (ch HealthCheck) doChecks(result *HealthResult) {
-	result.Checks = make([]CheckResult, len(ch.Checks))
-	wg := sync.WaitGroup{}
-	for i := 0; i < len(ch.Checks); i++ {
-		wg.Add(1)
-		go func(i int) {
-			result.Checks[i] = ch.Checks[i].runChecker()
-			wg.Done()
-		}(i)
-	}
-	wg.Wait()
+func (ch HealthCheck) policy() Policy {
+	return DefaultPolicy{}
 }
 
-func (chs HealthCheckSerial) doChecks(result *HealthResult) {
-	for _, checker := range chs.Checks {
-		result.Checks = append(result.Checks, checker.runChecker())
-	}
+func (ch HealthCheck) clock() Clock {
+	return systemClock{}
+}
+
+func (ch HealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	result.Checks = runChecks(ctx, ch.Checks, 0)
+}
+
+func (chs HealthCheckSerial) doChecks(ctx context.Context, result *HealthResult) {
+	result.Checks = runChecks(ctx, chs.Checks, 1)
+}
+
+func (bch BoundedHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	result.Checks = runChecks(ctx, bch.Checks, bch.MaxConcurrency)
 }
 
 func (fch FeedbackHealthCheck) initResult(result *HealthResult) {
 	fch.HC.initResult(result)
 }
 
-func (fch FeedbackHealthCheck) doChecks(result *HealthResult) {
-	fch.HC.doChecks(result)
-	fch.feedback <- ComputeOverallStatus(result)
+func (fch FeedbackHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	fch.HC.doChecks(ctx, result)
+	onRunCompleted(ctx, fch.HC, result, func(result *HealthResult) {
+		select {
+		case fch.feedback <- result.Ok:
+		default:
+		}
+	})
 }
 
-func (ch TimedHealthCheck) doChecks(result *HealthResult) {
-	lc := len(ch.Checks)
-	result.Checks = make([]CheckResult, lc)
-	wg := sync.WaitGroup{}
-	wg.Add(lc)
+func (ch TimedHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	if ch.Deadline != time.Duration(0) {
+		var cancel context.CancelFunc
+		ctx, cancel = withTimeoutCause(ctx, clockFrom(ctx), ch.Deadline, fmt.Errorf("Health check deadline of %v second(s) exceeded", ch.Deadline.Seconds()))
+		defer cancel()
+	}
+	checks := make([]Check, len(ch.Checks))
 	for i, c := range ch.Checks {
-		go func(i int, c Check) {
+		if c.Timeout == time.Duration(0) {
 			c.Timeout = ch.Timeout
-			result.Checks[i] = c.runChecker()
-			wg.Done()
-		}(i, c)
+		}
+		checks[i] = c
+	}
+	result.Checks = runChecks(ctx, checks, 0)
+}
+
+// runChecks runs the checks selected by ctx with at most limit of them running at once, or all at
+// once when limit is 0. A check only runs once the checks it depends on have passed. The results are in the same
+// order as the selected checks.
+func runChecks(ctx context.Context, checks []Check, limit int) []CheckResult {
+	if selected := selectChecks(ctx, checks); len(selected) != len(checks) {
+		all := checks
+		checks = make([]Check, len(selected))
+		for i, j := range selected {
+			checks[i] = all[j]
+		}
+	}
+	ctx, ff, cancel := startFailFast(ctx)
+	defer cancel()
+	results := make([]CheckResult, len(checks))
+	index := indexChecks(checks)
+	order, cyclic := dependencyOrder(checks, index)
+
+	// done[i] is closed once results[i] is final
+	done := make([]chan struct{}, len(checks))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	for _, i := range cyclic {
+		results[i] = checks[i].newResult()
+		results[i].LastUpdated = clockFrom(ctx).Now()
+		results[i].CheckOutput = "Check was not run as it is in, or depends on, a dependency cycle"
+		close(done[i])
+	}
+
+	var sem chan struct{}
+	if limit > 0 {
+		sem = make(chan struct{}, limit)
+	}
+	run := func(i int) {
+		defer close(done[i])
+		dep := blockingDependency(checks[i], func(id string) (CheckResult, bool) {
+			j, ok := index[id]
+			if !ok {
+				return CheckResult{}, false
+			}
+			<-done[j]
+			return results[j], true
+		})
+		if sem != nil && dep == "" {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+		}
+		if reason, stopped := ff.stopped(); stopped {
+			results[i] = checks[i].skippedResult(ctx, reason)
+			return
+		}
+		if dep != "" {
+			results[i] = checks[i].skippedResult(ctx, "depends on "+dep)
+			return
+		}
+		results[i] = ff.record(&checks[i], checks[i].runChecker(ctx))
+	}
+
+	if limit == 1 {
+		for _, i := range order {
+			run(i)
+		}
+		return results
+	}
+	wg := sync.WaitGroup{}
+	wg.Add(len(order))
+	for _, i := range order {
+		go func(i int) {
+			defer wg.Done()
+			run(i)
+		}(i)
 	}
 	wg.Wait()
+	return results
 }
@@ -1,8 +1,15 @@
 package v1_1
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -206,3 +213,865 @@ func assertTimedHealthCheck(t *testing.T, hc HC, el testCase) (result HealthResu
 	verifyTimePassedOK(el.timeout, actualDur, el.name, t)
 	return result
 }
+
+func TestContextCheckerIsCancelled(t *testing.T) {
+	testCases := [...]struct {
+		name    string
+		timeout time.Duration
+		cancel  bool
+		output  string
+	}{
+		{name: "Check timeout cancels checker", timeout: 100 * time.Millisecond, output: "Timed out after 0.1 second(s)"},
+		{name: "Caller cancellation cancels checker", cancel: true, output: "Check was cancelled: context canceled"},
+	}
+
+	for _, el := range testCases {
+		stopped := make(chan struct{})
+		check := Check{Severity: 1, Timeout: el.timeout, ContextChecker: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			close(stopped)
+			return "", ctx.Err()
+		}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if el.cancel {
+			time.AfterFunc(100*time.Millisecond, cancel)
+		}
+		result := RunCheckContext(ctx, HealthCheck{Checks: []Check{check}})
+		cancel()
+
+		verifyResultOK(result, 1, el.name, t)
+		if result.Checks[0].CheckOutput != el.output {
+			t.Errorf("TC name: %s, Error was: expected output %q but actual was %q \n", el.name, el.output, result.Checks[0].CheckOutput)
+		}
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Errorf("TC name: %s, Error was: checker was not cancelled \n", el.name)
+		}
+	}
+}
+
+func TestScheduledHealthCheck(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	checks := []Check{{ID: "scheduled", Severity: 1, Interval: 100 * time.Millisecond, Checker: func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		return "", nil
+	}}}
+	clock := NewFakeClock(time.Now())
+	hc := NewScheduledHealthCheck(HealthCheck{"up-mam", "Methode Article Mapper", "This mapps methode articles to internal UPP format.", checks}, time.Second)
+	hc.Clock = clock
+
+	result := RunCheck(hc)
+	verifyResultOK(result, 1, "Scheduled, not started", t)
+
+	hc.Start()
+	clock.BlockUntil(1)
+	for i := 0; i < 2; i++ {
+		clock.Advance(100 * time.Millisecond)
+		clock.BlockUntil(1)
+	}
+	first := RunCheck(hc)
+	second := RunCheck(hc)
+	hc.Stop()
+
+	verifyResultOK(first, 0, "Scheduled, started", t)
+	if !first.Checks[0].LastUpdated.Equal(second.Checks[0].LastUpdated) {
+		t.Errorf("Expected both requests to be served the same result, got %v and %v \n", first.Checks[0].LastUpdated, second.Checks[0].LastUpdated)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if runs != 3 {
+		t.Errorf("Expected the check to have run 3 times, got %d \n", runs)
+	}
+}
+
+func TestTimedHealthCheckPerCheckTimeoutsAndDeadline(t *testing.T) {
+	sleeper := func(d time.Duration) func() (string, error) {
+		return func() (string, error) {
+			time.Sleep(d)
+			return "", nil
+		}
+	}
+	checks := []Check{
+		{ID: "slow-but-fine", Severity: 3, Timeout: time.Second, Checker: sleeper(300 * time.Millisecond)},
+		{ID: "default-timeout", Severity: 2, Checker: sleeper(300 * time.Millisecond)},
+	}
+	hc := TimedHealthCheck{HealthCheck: HealthCheck{Checks: checks}, Timeout: 100 * time.Millisecond}
+	result := RunCheck(hc)
+	verifyResultOK(result, 2, "Per-check timeout overrides HC timeout", t)
+	if !result.Checks[0].Ok {
+		t.Errorf("Expected check with its own timeout to pass, output was %q \n", result.Checks[0].CheckOutput)
+	}
+	if result.Checks[1].CheckOutput != "Timed out after 0.1 second(s)" {
+		t.Errorf("Unexpected output for check using the HC timeout: %q \n", result.Checks[1].CheckOutput)
+	}
+
+	hc = TimedHealthCheck{HealthCheck: HealthCheck{Checks: checks[:1]}, Deadline: 100 * time.Millisecond}
+	start := time.Now()
+	result = RunCheck(hc)
+	if took := time.Since(start); took > 200*time.Millisecond {
+		t.Errorf("Expected the run to stop at the deadline, took %v \n", took)
+	}
+	verifyResultOK(result, 3, "Run deadline", t)
+	if result.Checks[0].CheckOutput != "Health check deadline of 0.1 second(s) exceeded" {
+		t.Errorf("Unexpected output for check past the deadline: %q \n", result.Checks[0].CheckOutput)
+	}
+}
+
+func TestBoundedHealthCheck(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	clock := NewFakeClock(time.Now())
+	checks := make([]Check, 6)
+	for i := range checks {
+		checks[i].ID = fmt.Sprintf("check-%d", i)
+		checks[i].Checker = func() (string, error) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+			<-clock.After(100 * time.Millisecond)
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return "", nil
+		}
+	}
+
+	results := make(chan HealthResult, 1)
+	go func() {
+		results <- RunCheck(NewClockHealthCheck(BoundedHealthCheck{HealthCheck: HealthCheck{Checks: checks}, MaxConcurrency: 2}, clock))
+	}()
+	for batch := 0; batch < 3; batch++ {
+		clock.BlockUntil(2)
+		clock.Advance(100 * time.Millisecond)
+	}
+	result := <-results
+
+	verifyChecksAreOK(result, "Bounded parallelism", t)
+	mu.Lock()
+	defer mu.Unlock()
+	if maxRunning != 2 {
+		t.Errorf("Expected at most 2 checks running at once, got %d \n", maxRunning)
+	}
+	if result.Duration != 300*time.Millisecond {
+		t.Errorf("Expected the checks to run in 3 batches, took %v \n", result.Duration)
+	}
+	for i, check := range result.Checks {
+		if check.ID != checks[i].ID {
+			t.Errorf("Expected result %d to be for %s, got %s \n", i, checks[i].ID, check.ID)
+		}
+	}
+}
+
+func TestCheckRetryPolicy(t *testing.T) {
+	errTransient := errors.New("connection reset")
+	errPermanent := errors.New("authentication failed")
+	flaky := func(failures int, err error) func() (string, error) {
+		calls := 0
+		return func() (string, error) {
+			calls++
+			if calls <= failures {
+				return "", err
+			}
+			return "recovered", nil
+		}
+	}
+	retryIf := func(err error) bool { return err == errTransient }
+
+	testCases := [...]struct {
+		name     string
+		check    Check
+		ok       bool
+		attempts int
+		previous int
+	}{
+		{name: "Succeeds after retries", ok: true, attempts: 3, previous: 2,
+			check: Check{Checker: flaky(2, errTransient), Retry: &RetryPolicy{MaxAttempts: 3, Backoff: 10 * time.Millisecond}}},
+		{name: "Gives up after max attempts", ok: false, attempts: 2, previous: 1,
+			check: Check{Checker: flaky(2, errTransient), Retry: &RetryPolicy{MaxAttempts: 2, Backoff: 10 * time.Millisecond, Jitter: 0.5}}},
+		{name: "Does not retry other errors", ok: false, attempts: 1, previous: 0,
+			check: Check{Checker: flaky(2, errPermanent), Retry: &RetryPolicy{MaxAttempts: 3, RetryIf: retryIf}}},
+		{name: "Retries stay within the timeout", ok: false, attempts: 1, previous: 1,
+			check: Check{Checker: flaky(2, errTransient), Timeout: 100 * time.Millisecond, Retry: &RetryPolicy{MaxAttempts: 3, Backoff: time.Second}}},
+	}
+
+	for _, el := range testCases {
+		start := time.Now()
+		result := el.check.runChecker(context.Background())
+		if time.Since(start) > 500*time.Millisecond {
+			t.Errorf("TC name: %s, Error was: check took %v \n", el.name, time.Since(start))
+		}
+		if result.Ok != el.ok || result.Attempts != el.attempts || len(result.PreviousErrors) != el.previous {
+			t.Errorf("TC name: %s, Error was: expected ok %t after %d attempts with %d earlier errors, got %t after %d with %v \n",
+				el.name, el.ok, el.attempts, el.previous, result.Ok, result.Attempts, result.PreviousErrors)
+		}
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	sleepThen := func(err error) func() (string, error) {
+		return func() (string, error) {
+			time.Sleep(200 * time.Millisecond)
+			return "", err
+		}
+	}
+	checks := []Check{
+		{ID: "query-neo4j", Severity: 1, DependsOn: []string{"neo4j-connectivity"}, Checker: sleepThen(nil)},
+		{ID: "neo4j-connectivity", Severity: 2, Checker: sleepThen(errors.New("Failure"))},
+		{ID: "query-neo4j-again", Severity: 1, DependsOn: []string{"query-neo4j", "not-a-check"}, Checker: sleepThen(nil)},
+		{ID: "independent", Severity: 1, Checker: sleepThen(nil)},
+		{ID: "cycle-a", Severity: 3, DependsOn: []string{"cycle-b"}, Checker: sleepThen(nil)},
+		{ID: "cycle-b", Severity: 3, DependsOn: []string{"cycle-a"}, Checker: sleepThen(nil)},
+	}
+	expected := []struct {
+		ok      bool
+		skipped bool
+		output  string
+	}{
+		{false, true, "skipped: depends on neo4j-connectivity"},
+		{false, false, "Failure"},
+		{false, true, "skipped: depends on query-neo4j"},
+		{true, false, ""},
+		{false, false, "Check was not run as it is in, or depends on, a dependency cycle"},
+		{false, false, "Check was not run as it is in, or depends on, a dependency cycle"},
+	}
+
+	for _, hc := range []HC{HealthCheck{Checks: checks}, HealthCheckSerial{HealthCheck{Checks: checks}}} {
+		start := time.Now()
+		result := RunCheck(hc)
+		took := time.Since(start)
+
+		verifyResultOK(result, 2, "Dependencies", t)
+		for i, exp := range expected {
+			check := result.Checks[i]
+			if check.ID != checks[i].ID || check.Ok != exp.ok || check.Skipped != exp.skipped || check.CheckOutput != exp.output {
+				t.Errorf("Expected %s to have ok %t, skipped %t and output %q, got %+v \n", checks[i].ID, exp.ok, exp.skipped, exp.output, check)
+			}
+		}
+		if _, serial := hc.(HealthCheckSerial); !serial && took > 350*time.Millisecond {
+			t.Errorf("Expected independent checks to run in parallel, took %v \n", took)
+		}
+	}
+}
+
+func TestFlapSuppressingHealthCheck(t *testing.T) {
+	outcomes := []error{nil, errors.New("Failure"), errors.New("Failure"), errors.New("Failure"), nil, errors.New("Failure"), nil, nil}
+	reported := []bool{true, true, true, false, false, false, false, true}
+
+	run := 0
+	checks := []Check{{ID: "flaky", Severity: 1, Checker: func() (string, error) {
+		err := outcomes[run]
+		run++
+		return "", err
+	}}}
+	hc := NewFlapSuppressingHealthCheck(HealthCheckSerial{HealthCheck{Checks: checks}}, 3, 2)
+	for i, exp := range reported {
+		result := RunCheck(hc)
+		if result.Checks[0].Ok != exp || result.Ok != exp {
+			t.Errorf("Run %d: expected ok %t, got %+v \n", i, exp, result.Checks[0])
+		}
+	}
+	failing := []Check{{ID: "failing", Severity: 1, Checker: func() (string, error) { return "", errors.New("Failure") }}}
+	hc = NewFlapSuppressingHealthCheck(HealthCheckSerial{HealthCheck{Checks: failing}}, 3, 2)
+	for i, exp := range []bool{true, true, false} {
+		if result := RunCheck(hc); result.Ok != exp {
+			t.Errorf("Failing from the first run %d: expected ok %t, got %+v \n", i, exp, result.Checks[0])
+		}
+	}
+}
+
+func TestFlapSuppressingHealthCheckCountsScheduledRunsOnce(t *testing.T) {
+	var mu sync.Mutex
+	runs := 0
+	checks := []Check{{ID: "scheduled", Severity: 1, Checker: func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		if runs > 1 {
+			return "", errors.New("Failure")
+		}
+		return "", nil
+	}}}
+	clock := NewFakeClock(time.Now())
+	scheduled := NewScheduledHealthCheck(HealthCheck{Checks: checks}, time.Minute)
+	scheduled.Clock = clock
+	hc := NewFlapSuppressingHealthCheck(scheduled, 2, 3)
+
+	verifyResultOK(RunCheck(hc), 1, "Scheduled checks not started", t)
+	scheduled.Start()
+	defer scheduled.Stop()
+	clock.BlockUntil(1)
+	verifyResultOK(RunCheck(hc), 0, "First scheduled run after placeholders", t)
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	for i := 0; i < 3; i++ {
+		verifyResultOK(RunCheck(hc), 0, "Second scheduled run read several times", t)
+	}
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	verifyResultOK(RunCheck(hc), 1, "Third scheduled run", t)
+}
+
+func TestFlapSuppressingHealthCheckCountsRunsAtTheSameTime(t *testing.T) {
+	run := 0
+	checks := []Check{{ID: "frozen", Severity: 1, Checker: func() (string, error) {
+		run++
+		if run > 1 {
+			return "", errors.New("Failure")
+		}
+		return "", nil
+	}}}
+	hc := NewFlapSuppressingHealthCheck(NewClockHealthCheck(HealthCheck{Checks: checks}, NewFakeClock(time.Now())), 2, 1)
+	for i, exp := range []bool{true, true, false, false, false} {
+		if result := RunCheck(hc); result.Ok != exp {
+			t.Errorf("Run %d: expected ok %t, got %+v \n", i, exp, result.Checks[0])
+		}
+	}
+}
+
+func TestGracePeriodHealthCheck(t *testing.T) {
+	run := 0
+	checks := []Check{
+		{ID: "warming-up", Severity: 1, Checker: func() (string, error) {
+			run++
+			if run == 2 {
+				return "", nil
+			}
+			return "", errors.New("cache is empty")
+		}},
+		{ID: "fine", Severity: 2, Checker: func() (string, error) { return "", nil }},
+	}
+	hc := NewGracePeriodHealthCheck(HealthCheckSerial{HealthCheck{Checks: checks}}, time.Minute)
+
+	result := RunCheck(hc)
+	verifyResultOK(result, 0, "Failure during grace period", t)
+	if !result.Checks[0].InGracePeriod || result.Checks[0].Ok || result.Checks[0].CheckOutput != "in startup grace period, 1m0s remaining: cache is empty" {
+		t.Errorf("Expected the failure to be reported as in the grace period, got %+v \n", result.Checks[0])
+	}
+	verifyResultOK(RunCheck(hc), 0, "Every check passed once", t)
+	verifyResultOK(RunCheck(hc), 1, "Failure after every check passed once", t)
+
+	hc = NewGracePeriodHealthCheck(HealthCheckSerial{HealthCheck{Checks: checks}}, 100*time.Millisecond)
+	verifyResultOK(RunCheck(hc), 0, "Failure during grace period", t)
+	time.Sleep(100 * time.Millisecond)
+	verifyResultOK(RunCheck(hc), 1, "Failure after grace period", t)
+}
+
+func TestGracePeriodHealthCheckUsesOneClock(t *testing.T) {
+	checks := []Check{{ID: "warming-up", Severity: 1, Checker: func() (string, error) { return "", errors.New("cache is empty") }}}
+	clock := NewFakeClock(time.Now())
+	grace := NewGracePeriodHealthCheck(NewClockHealthCheck(HealthCheck{Checks: checks}, clock), time.Minute)
+	hc := NewClockHealthCheck(grace, NewFakeClock(time.Now().Add(-time.Hour)))
+
+	verifyResultOK(RunCheck(hc), 0, "Failure during grace period", t)
+	clock.Advance(time.Minute)
+	verifyResultOK(RunCheck(hc), 1, "Failure after grace period", t)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	calls := 0
+	outcomes := []error{errors.New("Failure"), errors.New("Failure"), errors.New("Still failing"), nil}
+	check := Check{ID: "expensive", Severity: 1, CircuitBreaker: NewCircuitBreaker(2, 100*time.Millisecond), Checker: func() (string, error) {
+		err := outcomes[calls]
+		calls++
+		return "", err
+	}}
+
+	steps := []struct {
+		name   string
+		wait   time.Duration
+		ok     bool
+		calls  int
+		state  string
+		output string
+	}{
+		{name: "First failure", calls: 1, state: CircuitClosed, output: "Failure"},
+		{name: "Failure threshold reached", calls: 2, state: CircuitOpen, output: "Failure"},
+		{name: "Open circuit fails fast", calls: 2, state: CircuitOpen, output: "Circuit breaker is open, last error was: Failure"},
+		{name: "Failed trial reopens the circuit", wait: 100 * time.Millisecond, calls: 3, state: CircuitOpen, output: "Still failing"},
+		{name: "Reopened circuit fails fast", calls: 3, state: CircuitOpen, output: "Circuit breaker is open, last error was: Still failing"},
+		{name: "Passed trial closes the circuit", wait: 100 * time.Millisecond, ok: true, calls: 4, state: CircuitClosed},
+	}
+	for _, step := range steps {
+		time.Sleep(step.wait)
+		result := check.runChecker(context.Background())
+		if result.Ok != step.ok || calls != step.calls || result.CircuitState != step.state || result.CheckOutput != step.output {
+			t.Errorf("%s: expected ok %t, %d calls, circuit %s and output %q, got %t, %d, %s and %q \n",
+				step.name, step.ok, step.calls, step.state, step.output, result.Ok, calls, result.CircuitState, result.CheckOutput)
+		}
+	}
+}
+
+func TestCompositeCheck(t *testing.T) {
+	replicas := []Check{
+		{ID: "replica-1", Checker: func() (string, error) { return "reachable", nil }},
+		{ID: "replica-2", Name: "Replica 2", Checker: func() (string, error) { return "", errors.New("connection refused") }},
+		{ID: "replica-3", Checker: func() (string, error) { return "", nil }},
+	}
+	breakdown := "\n[ok] replica-1: reachable\n[failed] Replica 2: connection refused\n[ok] replica-3"
+
+	testCases := [...]struct {
+		name   string
+		quorum Quorum
+		ok     bool
+		output string
+	}{
+		{name: "All of", quorum: AllOf(), ok: false, output: "2 of 3 passed, 3 needed" + breakdown},
+		{name: "Any of", quorum: AnyOf(), ok: true, output: "2 of 3 passed, 1 needed" + breakdown},
+		{name: "At least 2", quorum: AtLeast(2), ok: true, output: "2 of 3 passed, 2 needed" + breakdown},
+	}
+	for _, el := range testCases {
+		check, err := NewCompositeCheck(Check{ID: "neo4j-cluster", Severity: 1}, el.quorum, replicas...)
+		if err != nil {
+			t.Fatalf("TC name: %s, Error was: %v", el.name, err)
+		}
+		ctx := WithFilter(context.Background(), CheckFilter{Tags: []string{"cluster"}})
+		result := RunCheckContext(ctx, HealthCheck{Checks: []Check{check}})
+		if len(result.Checks) != 0 {
+			t.Errorf("TC name: %s, Error was: expected the untagged composite check to be filtered out \n", el.name)
+		}
+
+		check.Tags = []string{"cluster"}
+		result = RunCheckContext(ctx, HealthCheck{Checks: []Check{check}})
+		if result.Checks[0].Ok != el.ok || result.Checks[0].CheckOutput != el.output {
+			t.Errorf("TC name: %s, Error was: expected ok %t with output %q, got %t with %q \n", el.name, el.ok, el.output, result.Checks[0].Ok, result.Checks[0].CheckOutput)
+		}
+	}
+
+	for _, n := range []int{0, -1, 4} {
+		if _, err := NewCompositeCheck(Check{ID: "neo4j-cluster"}, AtLeast(n), replicas...); err == nil {
+			t.Errorf("Expected a quorum of %d over %d replicas to be rejected \n", n, len(replicas))
+		}
+	}
+}
+
+func TestPolicyHealthCheck(t *testing.T) {
+	fail := func() (string, error) { return "", errors.New("Failure") }
+	ok := func() (string, error) { return "", nil }
+	checks := []Check{
+		{ID: "warning", Severity: 3, Checker: fail},
+		{ID: "important", Severity: 2, Checker: ok},
+		{ID: "critical", Severity: 1, Checker: ok},
+		{ID: "cache", Severity: 2, Checker: fail},
+	}
+	testCases := [...]struct {
+		name     string
+		policy   Policy
+		checks   []Check
+		severity uint8
+	}{
+		{name: "Default policy", policy: DefaultPolicy{}, checks: checks[:3], severity: 3},
+		{name: "Severity policy ignores warnings", policy: SeverityPolicy{MaxSeverity: 2}, checks: checks[:3], severity: 0},
+		{name: "Severity policy fails on important checks", policy: SeverityPolicy{MaxSeverity: 2}, checks: checks, severity: 2},
+		{name: "Fraction of checks below threshold", policy: WeightedPolicy{Threshold: 0.6}, checks: checks, severity: 0},
+		{name: "Fraction of checks reaches threshold", policy: WeightedPolicy{Threshold: 0.5}, checks: checks, severity: 2},
+		{name: "Weighted checks", policy: WeightedPolicy{Weights: map[string]float64{"warning": 0.5, "cache": 0.5, "critical": 3}, Threshold: 0.25}, checks: checks, severity: 0},
+	}
+	for _, el := range testCases {
+		result := RunCheck(NewPolicyHealthCheck(HealthCheck{Checks: el.checks}, el.policy))
+		verifyResultOK(result, el.severity, el.name, t)
+	}
+}
+
+func TestObservedHealthCheck(t *testing.T) {
+	run := 0
+	checks := []Check{{ID: "counter", Severity: 1, Checker: func() (string, error) {
+		run++
+		return fmt.Sprintf("run %d", run), nil
+	}}}
+	hc := NewObservedHealthCheck(HealthCheckSerial{HealthCheck{Checks: checks}})
+
+	release := make(chan struct{})
+	received := make(chan string, 10)
+	unsubscribe := hc.Subscribe(func(result HealthResult) {
+		received <- result.Checks[0].CheckOutput
+		<-release
+	})
+	unsubscribeBlocked := hc.SubscribeChan(make(chan HealthResult))
+	buffered := make(chan HealthResult, 1)
+	unsubscribeBuffered := hc.SubscribeChan(buffered)
+
+	done := make(chan struct{})
+	go func() {
+		RunCheck(hc)
+		time.Sleep(50 * time.Millisecond)
+		RunCheck(hc)
+		RunCheck(hc)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected slow subscribers not to block the runs")
+	}
+	close(release)
+	var outputs []string
+	for len(outputs) < 2 {
+		select {
+		case output := <-received:
+			outputs = append(outputs, output)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected 2 results for the busy subscriber, got %v", outputs)
+		}
+	}
+	if !reflect.DeepEqual(outputs, []string{"run 1", "run 3"}) {
+		t.Errorf("Expected the busy subscriber to get the first and latest results, got %v \n", outputs)
+	}
+
+	unsubscribe()
+	unsubscribeBlocked()
+	unsubscribeBuffered()
+	RunCheck(hc)
+	time.Sleep(50 * time.Millisecond)
+	if len(received) != 0 {
+		t.Errorf("Expected no results after unsubscribing, got %d \n", len(received))
+	}
+	if result := <-buffered; !result.Ok || result.Checks[0].CheckOutput != "run 1" {
+		t.Errorf("Expected the buffered channel to keep the first result, got %+v \n", result)
+	}
+}
+
+func TestObserversSeeTheOutermostPolicy(t *testing.T) {
+	run := 0
+	checks := []Check{{ID: "minor", Severity: 3, Checker: func() (string, error) {
+		run++
+		if run > 1 {
+			return "", errors.New("Failure")
+		}
+		return "", nil
+	}}}
+	var changes []StatusChange
+	observed := NewObservedHealthCheck(NewTransitionHealthCheck(HealthCheck{Checks: checks}, func(change StatusChange) {
+		changes = append(changes, change)
+	}))
+	results := make(chan HealthResult, 1)
+	defer observed.SubscribeChan(results)()
+	hc := NewPolicyHealthCheck(observed, SeverityPolicy{MaxSeverity: 2})
+
+	RunCheck(hc)
+	<-results
+	result := RunCheck(hc)
+	verifyResultOK(result, 0, "Severity 3 failure under SeverityPolicy", t)
+	observedResult := <-results
+	if observedResult.Ok != result.Ok || observedResult.Severity != result.Severity || observedResult.Duration != result.Duration {
+		t.Errorf("Expected subscribers to get the result of the response, got ok %t severity %d \n", observedResult.Ok, observedResult.Severity)
+	}
+
+	RunCheckContext(withSelector(context.Background(), GateByID("unknown")), hc)
+	if len(results) != 0 {
+		t.Errorf("Expected subscribers not to get the result of a filtered run, got %+v \n", <-results)
+	}
+	if observedResult.Checks[0].Since == nil {
+		t.Errorf("Expected subscribers to see the results set by the transitions \n")
+	}
+	if len(changes) != 1 || changes[0].Overall {
+		t.Errorf("Expected only the check to change status, got %+v \n", changes)
+	}
+}
+
+func TestFeedbackHealthCheckDoesNotBlock(t *testing.T) {
+	hc := NewFeedbackHealthCheck(HealthCheck{Checks: []Check{{Checker: func() (string, error) { return "", nil }}}}, make(chan bool))
+	done := make(chan struct{})
+	go func() {
+		RunCheck(hc)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("Expected the run not to wait for the feedback channel to be read")
+	}
+}
+
+func TestTransitionHealthCheck(t *testing.T) {
+	outcomes := []error{nil, errors.New("Failure"), errors.New("Failure"), nil}
+	run := 0
+	checks := []Check{
+		{ID: "flipping", Severity: 2, Checker: func() (string, error) {
+			err := outcomes[run]
+			run++
+			return "", err
+		}},
+		{ID: "steady", Severity: 1, Checker: func() (string, error) { return "", nil }},
+	}
+	var changes []StatusChange
+	hc := NewTransitionHealthCheck(HealthCheck{SystemCode: "up-mam", Checks: checks}, func(change StatusChange) {
+		changes = append(changes, change)
+	})
+
+	first := RunCheck(hc)
+	if first.Checks[0].PreviousOk != nil || first.Checks[0].Since == nil || len(changes) != 0 {
+		t.Errorf("Expected no changes on the first run, got %v and %+v \n", changes, first.Checks[0])
+	}
+	time.Sleep(50 * time.Millisecond)
+	failed := RunCheck(hc)
+	RunCheck(hc)
+	recovered := RunCheck(hc)
+
+	if len(changes) != 4 {
+		t.Fatalf("Expected 4 changes, got %+v", changes)
+	}
+	expected := []struct {
+		overall bool
+		id      string
+		ok      bool
+	}{{false, "flipping", false}, {true, "up-mam", false}, {false, "flipping", true}, {true, "up-mam", true}}
+	for i, exp := range expected {
+		change := changes[i]
+		if change.Overall != exp.overall || change.Current.ID != exp.id || change.Current.Ok != exp.ok || change.Previous.Ok == exp.ok {
+			t.Errorf("Change %d: expected %+v, got %+v \n", i, exp, change)
+		}
+	}
+	if changes[0].Lasted < 50*time.Millisecond || !changes[0].At.Equal(failed.Checks[0].LastUpdated) {
+		t.Errorf("Expected the ok status to have lasted since the first run, got %+v \n", changes[0])
+	}
+	if check := recovered.Checks[0]; check.PreviousOk == nil || *check.PreviousOk || !check.Since.Equal(check.LastUpdated) {
+		t.Errorf("Expected the recovered check to have been failing before, got %+v \n", check)
+	}
+	if check := recovered.Checks[1]; check.PreviousOk != nil || !check.Since.Equal(first.Checks[1].LastUpdated) {
+		t.Errorf("Expected the steady check to be ok since the first run, got %+v \n", check)
+	}
+}
+
+func TestTransitionHealthCheckIgnoresFilteredRunsOverall(t *testing.T) {
+	checks := []Check{
+		{ID: "critical", Severity: 1, Checker: func() (string, error) { return "", nil }},
+		{ID: "minor", Severity: 3, Checker: func() (string, error) { return "", errors.New("Failure") }},
+	}
+	var changes []StatusChange
+	hc := NewTransitionHealthCheck(HealthCheck{Checks: checks}, func(change StatusChange) {
+		changes = append(changes, change)
+	})
+	gated := withSelector(context.Background(), GateBySeverity(1))
+	for i := 0; i < 2; i++ {
+		RunCheck(hc)
+		RunCheckContext(gated, hc)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected runs of the critical checks alone not to change the overall status, got %+v \n", changes)
+	}
+}
+
+func TestFailFastHealthCheck(t *testing.T) {
+	cancelled := make(chan struct{}, 1)
+	checks := []Check{
+		{ID: "critical", Severity: 1, Checker: func() (string, error) {
+			time.Sleep(50 * time.Millisecond)
+			return "", errors.New("Failure")
+		}},
+		{ID: "slow", Severity: 2, ContextChecker: func(ctx context.Context) (string, error) {
+			select {
+			case <-ctx.Done():
+				cancelled <- struct{}{}
+				return "", ctx.Err()
+			case <-time.After(time.Second):
+				return "", nil
+			}
+		}},
+		{ID: "later", Severity: 1, DependsOn: []string{"slow"}, Checker: func() (string, error) { return "", nil }},
+	}
+
+	for _, hc := range []HC{NewFailFastHealthCheck(HealthCheck{Checks: checks}), NewFailFastHealthCheck(HealthCheckSerial{HealthCheck{Checks: checks}})} {
+		start := time.Now()
+		result := RunCheck(hc)
+		if took := time.Since(start); took > 300*time.Millisecond {
+			t.Errorf("Expected the run to stop after the critical failure, took %v \n", took)
+		}
+		verifyResultOK(result, 1, "Fail fast", t)
+		if result.Checks[0].Skipped || result.Checks[0].CheckOutput != "Failure" {
+			t.Errorf("Expected the critical check to be reported as failed, got %+v \n", result.Checks[0])
+		}
+		for _, check := range result.Checks[1:] {
+			if !check.Skipped || check.CheckOutput != "skipped: critical failed with severity 1" {
+				t.Errorf("Expected %s to be skipped, got %+v \n", check.ID, check)
+			}
+		}
+	}
+	select {
+	case <-cancelled:
+	default:
+		t.Error("Expected the running check to be cancelled")
+	}
+}
+
+func TestFailFastHealthCheckWithCompositeCheck(t *testing.T) {
+	ok := func() (string, error) { return "", nil }
+	cluster, err := NewCompositeCheck(Check{ID: "cluster", Severity: 2}, AtLeast(2),
+		Check{ID: "r1", Severity: 1, Checker: func() (string, error) { return "", errors.New("Failure") }},
+		Check{ID: "r2", Checker: ok},
+		Check{ID: "r3", Checker: ok},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := RunCheck(NewFailFastHealthCheck(HealthCheck{Checks: []Check{cluster}}))
+	const output = "2 of 3 passed, 2 needed\n[failed] r1: Failure\n[ok] r2\n[ok] r3"
+	if !result.Checks[0].Ok || result.Checks[0].CheckOutput != output {
+		t.Errorf("Expected the quorum to pass despite a severity 1 child failing, got %+v \n", result.Checks[0])
+	}
+}
+
+func TestPanickingCheckerStackTrace(t *testing.T) {
+	type panicValue struct{ code int }
+	testCases := [...]struct {
+		name    string
+		value   interface{}
+		timeout time.Duration
+		output  string
+	}{
+		{name: "String panic", value: "Checker did something unexpected", output: "Checker did something unexpected"},
+		{name: "Error panic, timed", value: errors.New("nil map"), timeout: time.Second, output: "nil map"},
+		{name: "Other panic", value: panicValue{42}, output: "{42}"},
+		{name: "Other panic, timed", value: panicValue{42}, timeout: time.Second, output: "{42}"},
+	}
+	for _, el := range testCases {
+		value := el.value
+		check := Check{ID: "panicking", Timeout: el.timeout, Checker: func() (string, error) {
+			panic(value)
+		}}
+		result := check.runChecker(context.Background())
+		if result.Ok || result.CheckOutput != el.output {
+			t.Errorf("TC name: %s, Error was: expected failure with output %q, got %+v \n", el.name, el.output, result)
+		}
+		if !strings.Contains(result.PanicStack, "TestPanickingCheckerStackTrace") {
+			t.Errorf("TC name: %s, Error was: expected the stack trace of the panic, got %q \n", el.name, result.PanicStack)
+		}
+		if out, _ := json.Marshal(result); strings.Contains(string(out), "TestPanickingCheckerStackTrace") {
+			t.Errorf("TC name: %s, Error was: expected no stack trace in JSON, got %s \n", el.name, out)
+		}
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2017, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	clock := NewFakeClock(start)
+	checks := []Check{{ID: "hanging", Severity: 1, ContextChecker: func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}}
+	hc := NewClockHealthCheck(TimedHealthCheck{HealthCheck: HealthCheck{Checks: checks}, Timeout: 10 * time.Second}, clock)
+	results := make(chan HealthResult)
+	go func() {
+		results <- RunCheck(hc)
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Second)
+	result := <-results
+	check := result.Checks[0]
+	if check.CheckOutput != "Timed out after 10 second(s)" || !check.LastUpdated.Equal(start) || check.Duration != 10*time.Second || result.Duration != 10*time.Second {
+		t.Errorf("Expected the check to time out after exactly 10 seconds, got %+v \n", check)
+	}
+
+	checks = []Check{{ID: "quick", Severity: 1, Timeout: time.Minute, Checker: func() (string, error) { return "", nil }}}
+	RunCheck(NewClockHealthCheck(HealthCheck{Checks: checks}, clock))
+	clock.mu.Lock()
+	waiting := len(clock.waiters)
+	clock.mu.Unlock()
+	if waiting != 0 {
+		t.Errorf("Expected the timeout of a finished check to be dropped, got %d timers \n", waiting)
+	}
+
+	clock = NewFakeClock(start)
+	calls := make(chan struct{}, 10)
+	checks = []Check{{ID: "retried", Severity: 1, Interval: time.Minute, Retry: &RetryPolicy{MaxAttempts: 2, Backoff: 5 * time.Second}, Checker: func() (string, error) {
+		calls <- struct{}{}
+		if len(calls)%2 == 1 {
+			return "", errors.New("Failure")
+		}
+		return "", nil
+	}}}
+	scheduled := NewScheduledHealthCheck(HealthCheck{Checks: checks}, 0)
+	scheduled.Clock = clock
+	scheduled.Start()
+	defer scheduled.Stop()
+
+	// Each step waits for the scheduler to be waiting on either the retry backoff or the interval
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	clock.BlockUntil(1)
+	check = RunCheck(scheduled).Checks[0]
+	if !check.Ok || check.Attempts != 2 || !check.LastUpdated.Equal(start) || check.Duration != 5*time.Second {
+		t.Errorf("Expected the first run to pass after a 5 second backoff, got %+v \n", check)
+	}
+	clock.Advance(time.Minute)
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	clock.BlockUntil(1)
+	if check = RunCheck(scheduled).Checks[0]; !check.LastUpdated.Equal(start.Add(65*time.Second)) || len(calls) != 4 {
+		t.Errorf("Expected the second run a minute after the first, got %+v \n", check)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	ok := func() (string, error) { return "", nil }
+	registry := NewRegistry("up-mam", "Methode Article Mapper", "This mapps methode articles to internal UPP format.")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var blocked atomic.Bool
+	if err := registry.Register(Check{ID: "topic-a", Severity: 1, Checker: func() (string, error) {
+		// Only the first run is held up, so that the registry is changed while it is in progress
+		if blocked.CompareAndSwap(false, true) {
+			close(started)
+			<-release
+		}
+		return "", nil
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(Check{ID: "topic-b", Checker: ok}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(Check{ID: "topic-b", Checker: ok}); err == nil {
+		t.Error("Expected registering a check with the same ID twice to fail")
+	}
+	if err := registry.Replace(Check{ID: "topic-c", Checker: ok}); err == nil {
+		t.Error("Expected replacing a check which is not registered to fail")
+	}
+
+	results := make(chan HealthResult)
+	go func() {
+		results <- RunCheck(registry)
+	}()
+	<-started
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			registry.Register(Check{ID: fmt.Sprintf("topic-%d", i), Checker: ok})
+			registry.Replace(Check{ID: "topic-b", Name: "Topic B", Checker: ok})
+			verifyResultOK(RunCheck(registry), 0, "Run while the registry changes", t)
+			if !registry.Unregister(fmt.Sprintf("topic-%d", i)) {
+				t.Errorf("Expected topic-%d to be registered \n", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+	registry.Unregister("topic-a")
+	close(release)
+
+	inFlight := <-results
+	if len(inFlight.Checks) != 2 || inFlight.Checks[0].ID != "topic-a" || inFlight.Checks[1].Name != "" {
+		t.Errorf("Expected the run in progress to keep the checks it started with, got %+v \n", inFlight.Checks)
+	}
+	var ids []string
+	for _, check := range registry.Checks() {
+		ids = append(ids, check.ID)
+	}
+	if len(ids) != 1 || ids[0] != "topic-b" || registry.Checks()[0].Name != "Topic B" {
+		t.Errorf("Expected only the replaced topic-b to be registered, got %v \n", ids)
+	}
+
+	registry.Checks()[0].Name = "Changed"
+	if name := registry.Checks()[0].Name; name != "Topic B" {
+		t.Errorf("Expected changing the returned checks not to change the registry, got %q \n", name)
+	}
+}
@@ -0,0 +1,59 @@
+package v1_1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GracePeriodHealthCheck stops failing checks from affecting the overall status while the service
+// starts up. The grace period ends after Period, or as soon as every check has passed once.
+type GracePeriodHealthCheck struct {
+	HC
+	Period time.Duration
+
+	start  time.Time
+	mu     sync.Mutex
+	passed map[string]bool
+	over   bool
+}
+
+// NewGracePeriodHealthCheck starts the grace period straight away, so it should be called when
+// the service starts. The period is measured with the clock of hc, even when the returned HC is
+// wrapped with another clock.
+func NewGracePeriodHealthCheck(hc HC, period time.Duration) *GracePeriodHealthCheck {
+	return &GracePeriodHealthCheck{HC: hc, Period: period, start: hc.clock().Now(), passed: make(map[string]bool)}
+}
+
+func (g *GracePeriodHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	g.HC.doChecks(ctx, result)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.over {
+		return
+	}
+	remaining := g.Period - g.HC.clock().Now().Sub(g.start)
+	allPassed := true
+	for i, check := range result.Checks {
+		key := resultKey(i, check)
+		if check.Ok {
+			g.passed[key] = true
+		}
+		allPassed = allPassed && g.passed[key]
+	}
+	// A run of some of the checks cannot tell whether every check has passed
+	if remaining <= 0 || (allPassed && !isFiltered(ctx)) {
+		g.over = true
+		return
+	}
+
+	for i := range result.Checks {
+		check := &result.Checks[i]
+		if !check.Ok && !check.Skipped {
+			check.InGracePeriod = true
+			check.CheckOutput = fmt.Sprintf("in startup grace period, %v remaining: %s", remaining.Round(time.Second), check.CheckOutput)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package v1_1
+
+import (
+	"net/http"
+	"strings"
+)
+
+// GTGHandler serves a good to go endpoint for hc. It responds 200 with "OK" when the checks
+// selected by gate pass, or 503 with the names of the failing checks. Only the selected checks are
+// run, all of them when gate is nil, and the same policy as for the health endpoint is applied.
+// Serve it from a ScheduledHealthCheck, or use WithCoalescing, to keep probes cheap.
+func GTGHandler(hc HC, gate func(Check) bool, opts ...HandlerOption) func(w http.ResponseWriter, r *http.Request) {
+	ch := newCheckHandler(hc, opts)
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if gate != nil {
+			ctx = withSelector(ctx, gate)
+		}
+		health, err := ch.runCheck(ctx, "")
+		if err != nil {
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=US-ASCII")
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		if health.Ok {
+			w.Write([]byte("OK"))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(strings.Join(failingChecks(health), "\n")))
+	}
+}
+
+// GateBySeverity selects the checks with a severity up to maxSeverity, e.g. 1 for only the
+// critical checks
+func GateBySeverity(maxSeverity uint8) func(Check) bool {
+	return func(check Check) bool {
+		return check.Severity <= maxSeverity
+	}
+}
+
+// GateByID selects the checks with the given IDs
+func GateByID(ids ...string) func(Check) bool {
+	return func(check Check) bool {
+		for _, id := range ids {
+			if check.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// failingChecks returns the names, or IDs when they have no name, of the checks which failed
+func failingChecks(health HealthResult) (names []string) {
+	for _, check := range health.Checks {
+		if check.failed() {
+			name := check.Name
+			if name == "" {
+				name = check.ID
+			}
+			names = append(names, name)
+		}
+	}
+	return
+}
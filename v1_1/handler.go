@@ -1,47 +1,203 @@
 package v1_1
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 )
 
 type checkHandler struct {
 	HC
+	coalesce   bool
+	timings    bool
+	statusCode func(HealthResult) int
+
+	mu       sync.Mutex
+	inflight map[string]*sharedRun
+}
+
+// sharedRun is a health check run whose result is handed to every request that waited for it
+type sharedRun struct {
+	done   chan struct{}
+	result HealthResult
 }
 
 type ErrorMessage struct {
 	Message string `json:"message"`
 }
 
-func Handler(hc HC) func(w http.ResponseWriter, r *http.Request) {
-	ch := checkHandler{hc}
-	return ch.handle
+// HandlerOption configures the handler returned by Handler
+type HandlerOption func(ch *checkHandler)
+
+// WithCoalescing makes requests arriving while the checks are already running wait for that run
+// and share its result, rather than running all the checks again.
+func WithCoalescing() HandlerOption {
+	return func(ch *checkHandler) {
+		ch.coalesce = true
+	}
 }
 
+// WithTimings adds when each check started, how long it took and its timeout to the output, along
+// with how long the whole run took.
+func WithTimings() HandlerOption {
+	return func(ch *checkHandler) {
+		ch.timings = true
+	}
+}
+
+// WithStatusCode sets the HTTP status of the response to statusCode of the result, rather than
+// always responding 200 as the FT health check standard requires. It is meant for monitors which
+// only look at the status, e.g. WithStatusCode(StatusBySeverity(map[uint8]int{1: 503})).
+func WithStatusCode(statusCode func(HealthResult) int) HandlerOption {
+	return func(ch *checkHandler) {
+		ch.statusCode = statusCode
+	}
+}
+
+// StatusBySeverity maps the overall severity of an unhealthy result to a status code. Healthy
+// results, and severities without a code, get 200.
+func StatusBySeverity(codes map[uint8]int) func(HealthResult) int {
+	return func(health HealthResult) int {
+		if code, ok := codes[health.Severity]; ok && !health.Ok {
+			return code
+		}
+		return http.StatusOK
+	}
+}
+
+func Handler(hc HC, opts ...HandlerOption) func(w http.ResponseWriter, r *http.Request) {
+	return newCheckHandler(hc, opts).handle
+}
+
+func newCheckHandler(hc HC, opts []HandlerOption) *checkHandler {
+	ch := &checkHandler{HC: hc}
+	for _, opt := range opts {
+		opt(ch)
+	}
+	return ch
+}
+
+// status is the HTTP status of the response for health
+func (ch *checkHandler) status(health HealthResult) int {
+	if ch.statusCode == nil {
+		return http.StatusOK
+	}
+	return ch.statusCode(health)
+}
+
+// runCheck runs the checks, or waits for a run already in progress when coalescing. Only runs of
+// the same checks are shared, so key has to identify which checks are selected by ctx. It returns
+// an error when ctx is done while waiting for another run, which carries on for the others.
+func (ch *checkHandler) runCheck(ctx context.Context, key string) (HealthResult, error) {
+	if !ch.coalesce {
+		return RunCheckContext(ctx, ch.HC), nil
+	}
+
+	ch.mu.Lock()
+	if run, ok := ch.inflight[key]; ok {
+		ch.mu.Unlock()
+		select {
+		case <-run.done:
+			return run.result, nil
+		case <-ctx.Done():
+			return HealthResult{}, contextError(ctx)
+		}
+	}
+	run := &sharedRun{done: make(chan struct{})}
+	if ch.inflight == nil {
+		ch.inflight = make(map[string]*sharedRun)
+	}
+	ch.inflight[key] = run
+	ch.mu.Unlock()
+
+	defer func() {
+		ch.mu.Lock()
+		delete(ch.inflight, key)
+		ch.mu.Unlock()
+		close(run.done)
+	}()
+	// Other requests depend on this run, so it must not stop when the request which started it does
+	run.result = RunCheckContext(context.WithoutCancel(ctx), ch.HC)
+	return run.result, nil
+}
+
+// handle serves the health check. The tag and exclude query parameters run only the checks with
+// the given tags or without them, e.g. /__health?tag=kafka or /__health?exclude=external
 func (ch *checkHandler) handle(w http.ResponseWriter, r *http.Request) {
-	health := RunCheck(ch)
+	ctx := r.Context()
+	key := ""
+	if filter := filterFromQuery(r.URL.Query()); filter != nil {
+		ctx = WithFilter(ctx, *filter)
+		key = filter.String()
+	}
+	health, err := ch.runCheck(ctx, key)
+	if err != nil {
+		// The request was abandoned, so there is no one to respond to
+		return
+	}
 
 	if strings.Contains(r.Header.Get("Accept"), "text/html") {
-		err := writeHTMLResp(w, health)
+		err := writeHTMLResp(w, ch.status(health), htmlView{health, ch.timings})
 		if err == nil {
 			return
 		}
 	}
 	w.Header().Set("Content-Type", "application/json")
-	enc := json.NewEncoder(w)
-	err := enc.Encode(health)
+	var body interface{} = health
+	if ch.timings {
+		body = withTimings(health)
+	}
+	resp, err := json.Marshal(body)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		msg, _ := json.Marshal(ErrorMessage{fmt.Sprintf("Failed to encode healthcheck response for % service, erorr was: %v", health.SystemCode, err)})
 		w.Write([]byte(msg))
 		return
 	}
+	w.WriteHeader(ch.status(health))
+	w.Write(append(resp, '\n'))
+}
+
+// timedHealthResult adds the timings to the JSON output of a HealthResult
+type timedHealthResult struct {
+	HealthResult
+	Checks     []timedCheckResult `json:"checks"`
+	StartedAt  time.Time          `json:"startedAt"`
+	DurationMs float64            `json:"durationMs"`
+}
+
+type timedCheckResult struct {
+	CheckResult
+	StartedAt  time.Time `json:"startedAt,omitzero"`
+	DurationMs float64   `json:"durationMs"`
+	TimeoutMs  float64   `json:"timeoutMs,omitempty"`
+}
+
+func withTimings(health HealthResult) timedHealthResult {
+	timed := timedHealthResult{HealthResult: health, StartedAt: health.StartedAt, DurationMs: milliseconds(health.Duration)}
+	timed.Checks = make([]timedCheckResult, len(health.Checks))
+	for i, check := range health.Checks {
+		timed.Checks[i] = timedCheckResult{check, check.StartedAt, milliseconds(check.Duration), milliseconds(check.Timeout)}
+	}
+	return timed
 }
 
-func writeHTMLResp(w http.ResponseWriter, health HealthResult) error {
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// htmlView is what the HTML template is rendered from
+type htmlView struct {
+	HealthResult
+	Timings bool
+}
+
+func writeHTMLResp(w http.ResponseWriter, status int, health htmlView) error {
 	w.Header().Set("Content-Type", "text/html")
 	t := template.New("healthchecks")
 	t, err := t.Parse(` <!DOCTYPE html>
@@ -62,6 +218,10 @@ func writeHTMLResp(w http.ResponseWriter, health HealthResult) error {
 				background-color: #b00;
 				color: #fff;
 			}
+			.skipped {
+				background-color: #999;
+				color: #fff;
+			}
 			.output {
 				background: #ccc;
 				border: solid thin #999;
@@ -75,6 +235,8 @@ func writeHTMLResp(w http.ResponseWriter, health HealthResult) error {
 		<table>
 			<tr><th>Description</th><td>{{ .Description }}</td></tr>
 			<tr><th>System Code</th><td>{{ .SystemCode }}</td></tr>
+			{{if .Timings }}<tr><th>Duration</th><td>{{ .Duration }}</td></tr>{{ end }}
+			{{if .Filter }}<tr><th>Filter</th><td>{{ .Filter }}</td></tr>{{ end }}
 			{{if .SystemCode }}<tr>
 				<th>Runbook</th>
 				<td><a href="https://runbooks.ftops.tech/{{ .SystemCode }}" target="__blank">https://runbooks.ftops.tech/{{ .SystemCode }}</a></td>
@@ -83,20 +245,28 @@ func writeHTMLResp(w http.ResponseWriter, health HealthResult) error {
 
 		<h2>Checks</h2>
 			{{ range $key, $value := .Checks }}
-				<h3 class="{{if $value.Ok }}ok{{ else }}error{{ end }}">{{ $value.Name }}</h3>
+				<h3 class="{{if $value.Ok }}ok{{ else if $value.Skipped }}skipped{{ else }}error{{ end }}">{{ $value.Name }}</h3>
 				<ul>
-					<li> Status: {{if $value.Ok }}OK{{ else }}Error{{ end }}</li>
+					<li> Status: {{if $value.Ok }}OK{{ else if $value.Skipped }}Skipped{{ else }}Error{{ end }}</li>
 					<li> Severity: {{ $value.Severity }} </li>
+					{{if $value.Ack }}<li> Acknowledged: {{ $value.Ack }} </li>{{ end }}
+					{{if $value.CircuitState }}<li> Circuit breaker: {{ $value.CircuitState }} </li>{{ end }}
 					<li> Business impact: {{ $value.BusinessImpact }} </li>
 					<li> Technical summary: {{ $value.TechnicalSummary }} </li>
 					{{if $value.PanicGuideIsLink }}<li> Panic guide: <a href="{{ $value.PanicGuide }}">{{ $value.PanicGuide }}</a> </li>
 					{{ else }}<li> Panic guide: <pre>{{ $value.PanicGuide }}</pre> </li>{{ end }}
 					{{if $value.CheckOutput }}<li> Output: <pre class='output'>{{ $value.CheckOutput }}</pre> </li>{{ end }}
+					{{if $value.PanicStack }}<li> Panic stack trace: <pre class='output'>{{ $value.PanicStack }}</pre> </li>{{ end }}
+					{{if $value.PreviousErrors }}<li> Earlier attempts: <pre class='output'>{{ range $value.PreviousErrors }}{{ . }}
+{{ end }}</pre> </li>{{ end }}
 					<li> Last updated: {{ $value.LastUpdated }} </li>
+					{{if $.Timings }}<li> Duration: {{ $value.Duration }}{{if $value.Timeout }} of a {{ $value.Timeout }} timeout{{ end }} </li>{{ end }}
+					{{if $value.Since }}<li> In this status since: {{ $value.Since }} </li>{{ end }}
 				</ul>
 			{{ end }}
 	</body>`)
 	if err == nil {
+		w.WriteHeader(status)
 		t.Execute(w, health)
 	}
 	return err
@@ -0,0 +1,394 @@
+package v1_1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandlerCoalescesConcurrentRequests(t *testing.T) {
+	var runs int32
+	checks := []Check{{ID: "slow", Severity: 1, Checker: func() (string, error) {
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(200 * time.Millisecond)
+		return "", nil
+	}}}
+	handler := Handler(HealthCheck{Checks: checks}, WithCoalescing())
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(http.MethodGet, "/__health", nil))
+			if w.Code != http.StatusOK {
+				t.Errorf("Expected status %d, got %d \n", http.StatusOK, w.Code)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if runs != 1 {
+		t.Errorf("Expected concurrent requests to share 1 run, got %d runs \n", runs)
+	}
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/__health", nil))
+	if runs != 2 {
+		t.Errorf("Expected a request after the shared run to run the checks again, got %d runs \n", runs)
+	}
+}
+
+func TestCoalescedRequestsCanBeAbandoned(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	checks := []Check{{ID: "slow", Severity: 1, Checker: func() (string, error) {
+		close(started)
+		<-release
+		return "", nil
+	}}}
+	handler := Handler(HealthCheck{Checks: checks}, WithCoalescing())
+
+	shared := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/__health", nil))
+		shared <- w
+	}()
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/__health", nil).WithContext(ctx))
+		abandoned <- w
+	}()
+	cancel()
+	select {
+	case w := <-abandoned:
+		if w.Body.Len() != 0 {
+			t.Errorf("Expected nothing to be written for the abandoned request, got %q \n", w.Body.String())
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the abandoned request to stop waiting for the shared run")
+	}
+
+	close(release)
+	if w := <-shared; w.Code != http.StatusOK || w.Body.Len() == 0 {
+		t.Errorf("Expected the shared run to carry on for the request which started it, got %d \n", w.Code)
+	}
+}
+
+func TestHandlerRunsChecksSelectedByTags(t *testing.T) {
+	ok := func() (string, error) { return "", nil }
+	checks := []Check{
+		{ID: "kafka-consumer", Tags: []string{"kafka", "critical"}, Checker: ok},
+		{ID: "kafka-producer", Tags: []string{"kafka"}, Checker: ok},
+		{ID: "external-api", Tags: []string{"external"}, Checker: ok},
+		{ID: "untagged", Checker: ok},
+	}
+	testCases := [...]struct {
+		query  string
+		ids    []string
+		filter *CheckFilter
+	}{
+		{query: "", ids: []string{"kafka-consumer", "kafka-producer", "external-api", "untagged"}},
+		{query: "?tag=kafka", ids: []string{"kafka-consumer", "kafka-producer"}, filter: &CheckFilter{Tags: []string{"kafka"}}},
+		{query: "?exclude=external", ids: []string{"kafka-consumer", "kafka-producer", "untagged"}, filter: &CheckFilter{Exclude: []string{"external"}}},
+		{query: "?tag=kafka,external&exclude=critical", ids: []string{"kafka-producer", "external-api"}, filter: &CheckFilter{Tags: []string{"kafka", "external"}, Exclude: []string{"critical"}}},
+	}
+
+	handler := Handler(HealthCheck{Checks: checks}, WithCoalescing())
+	for _, el := range testCases {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/__health"+el.query, nil))
+
+		var result HealthResult
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("Query %q: failed to decode response: %v", el.query, err)
+		}
+		var ids []string
+		for _, check := range result.Checks {
+			ids = append(ids, check.ID)
+		}
+		if !reflect.DeepEqual(ids, el.ids) {
+			t.Errorf("Query %q: expected checks %v, got %v \n", el.query, el.ids, ids)
+		}
+		if !reflect.DeepEqual(result.Filter, el.filter) {
+			t.Errorf("Query %q: expected filter %+v, got %+v \n", el.query, el.filter, result.Filter)
+		}
+	}
+}
+
+func TestHandlerWithTimings(t *testing.T) {
+	checks := []Check{{ID: "slow", Timeout: time.Second, Checker: func() (string, error) {
+		time.Sleep(100 * time.Millisecond)
+		return "", nil
+	}}}
+	for _, timings := range []bool{false, true} {
+		var opts []HandlerOption
+		if timings {
+			opts = append(opts, WithTimings())
+		}
+		w := httptest.NewRecorder()
+		Handler(HealthCheck{Checks: checks}, opts...)(w, httptest.NewRequest(http.MethodGet, "/__health", nil))
+
+		var result map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		check := result["checks"].([]interface{})[0].(map[string]interface{})
+		if !timings {
+			if _, ok := check["durationMs"]; ok {
+				t.Errorf("Expected no timings without WithTimings, got %v \n", check)
+			}
+			continue
+		}
+		if d, _ := check["durationMs"].(float64); d < 100 || d > 200 {
+			t.Errorf("Expected the check duration to be about 100ms, got %v \n", check["durationMs"])
+		}
+		if check["timeoutMs"] != float64(1000) || check["startedAt"] == nil || check["id"] != "slow" {
+			t.Errorf("Expected the check timeout, start and fields, got %v \n", check)
+		}
+		if d, _ := result["durationMs"].(float64); d < 100 {
+			t.Errorf("Expected the run duration to cover the check, got %v \n", result["durationMs"])
+		}
+	}
+}
+
+func TestGTGHandler(t *testing.T) {
+	var runs int32
+	counted := func(err error) func() (string, error) {
+		return func() (string, error) {
+			atomic.AddInt32(&runs, 1)
+			return "", err
+		}
+	}
+	checks := []Check{
+		{ID: "neo4j", Name: "Neo4j connectivity", Severity: 1, Checker: counted(nil)},
+		{ID: "kafka", Name: "Kafka connectivity", Severity: 2, Checker: counted(errors.New("Failure"))},
+		{ID: "cache", Severity: 3, Checker: counted(errors.New("Failure"))},
+	}
+	testCases := [...]struct {
+		name string
+		gate func(Check) bool
+		code int
+		body string
+		runs int32
+	}{
+		{name: "All checks", code: http.StatusServiceUnavailable, body: "Kafka connectivity\ncache", runs: 3},
+		{name: "Critical checks", gate: GateBySeverity(1), code: http.StatusOK, body: "OK", runs: 1},
+		{name: "Checks by ID", gate: GateByID("neo4j", "cache"), code: http.StatusServiceUnavailable, body: "cache", runs: 2},
+	}
+	for _, el := range testCases {
+		atomic.StoreInt32(&runs, 0)
+		w := httptest.NewRecorder()
+		GTGHandler(HealthCheck{Checks: checks}, el.gate)(w, httptest.NewRequest(http.MethodGet, "/__gtg", nil))
+		if w.Code != el.code || w.Body.String() != el.body || runs != el.runs {
+			t.Errorf("TC name: %s, Error was: expected %d %q after %d checks, got %d %q after %d \n", el.name, el.code, el.body, el.runs, w.Code, w.Body.String(), runs)
+		}
+	}
+}
+
+func TestProbeHandlers(t *testing.T) {
+	var mu sync.Mutex
+	dbUp := false
+	checks := []Check{
+		{ID: "goroutines", Tags: []string{TagLiveness}, Checker: func() (string, error) { return "", nil }},
+		{ID: "db", Severity: 1, Checker: func() (string, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if !dbUp {
+				return "", errors.New("Failure")
+			}
+			return "", nil
+		}},
+	}
+	hc := HealthCheck{Checks: checks}
+	liveness := LivenessHandler(hc, ProbeConfig{})
+	readiness := ReadinessHandler(hc, ProbeConfig{})
+	startup := StartupHandler(hc, ProbeConfig{})
+	setDB := func(up bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		dbUp = up
+	}
+
+	steps := [...]struct {
+		name    string
+		handler func(w http.ResponseWriter, r *http.Request)
+		dbUp    bool
+		query   string
+		code    int
+		body    string
+	}{
+		{name: "Liveness ignores dependencies", handler: liveness, query: "", code: http.StatusOK, body: "ok"},
+		{name: "Liveness verbose", handler: liveness, query: "?verbose", code: http.StatusOK, body: "[+]goroutines ok\nlivez check passed\n"},
+		{name: "Readiness fails on dependencies", handler: readiness, query: "", code: http.StatusInternalServerError, body: "[+]goroutines ok\n[-]db failed: reason withheld\nreadyz check failed\n"},
+		{name: "Readiness with excluded check", handler: readiness, query: "?exclude=db&verbose", code: http.StatusOK, body: "[+]goroutines ok\n[+]db excluded: ok\nreadyz check passed\n"},
+		{name: "Startup with the failing check excluded", handler: startup, query: "?exclude=db", code: http.StatusOK, body: "ok"},
+		{name: "Startup before every check passed", handler: startup, query: "", code: http.StatusInternalServerError, body: "[+]goroutines ok\n[-]db failed: reason withheld\nstartupz check failed\n"},
+		{name: "Startup once every check passed", handler: startup, dbUp: true, query: "?verbose", code: http.StatusOK, body: "[+]goroutines ok\n[+]db ok\nstartupz check passed\n"},
+		{name: "Startup keeps passing", handler: startup, query: "", code: http.StatusOK, body: "ok"},
+		{name: "Readiness follows dependencies", handler: readiness, query: "", code: http.StatusInternalServerError, body: "[+]goroutines ok\n[-]db failed: reason withheld\nreadyz check failed\n"},
+	}
+	for _, step := range steps {
+		setDB(step.dbUp)
+		w := httptest.NewRecorder()
+		step.handler(w, httptest.NewRequest(http.MethodGet, "/probe"+step.query, nil))
+		if w.Code != step.code || w.Body.String() != step.body {
+			t.Errorf("%s: expected %d %q, got %d %q \n", step.name, step.code, step.body, w.Code, w.Body.String())
+		}
+	}
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	deadlocked := HealthCheck{Checks: []Check{{ID: "stuck", Tags: []string{TagLiveness}, Checker: func() (string, error) {
+		<-stuck
+		return "", nil
+	}}}}
+	w := httptest.NewRecorder()
+	LivenessHandler(deadlocked, ProbeConfig{Timeout: 100 * time.Millisecond})(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "[-]checks did not finish within 100ms\nlivez check failed\n" {
+		t.Errorf("Expected liveness to fail when the checks hang, got %d %q \n", w.Code, w.Body.String())
+	}
+}
+
+func TestSingleCheckHandler(t *testing.T) {
+	var runs int32
+	checks := []Check{
+		{ID: "neo4j", Name: "Neo4j", Severity: 1, Checker: func() (string, error) {
+			return "", errors.New("Failure")
+		}},
+		{ID: "kafka", Name: "Kafka", Checker: func() (string, error) {
+			atomic.AddInt32(&runs, 1)
+			return "", nil
+		}},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/__health/{checkID}", SingleCheckHandler(HealthCheck{Checks: checks}))
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/__health/neo4j", nil))
+	var result CheckResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode check result: %v", err)
+	}
+	if w.Code != http.StatusOK || result.ID != "neo4j" || result.Ok || result.CheckOutput != "Failure" {
+		t.Errorf("Expected the result of neo4j, got %d %s \n", w.Code, w.Body.String())
+	}
+	if atomic.LoadInt32(&runs) != 0 {
+		t.Errorf("Expected only the requested check to run \n")
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/__health/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown check, got %d \n", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	SingleCheckHandler(HealthCheck{Checks: checks})(w, httptest.NewRequest(http.MethodGet, "/checks/kafka", nil))
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || result.ID != "kafka" || !result.Ok {
+		t.Errorf("Expected the ID to be taken from the path without a pattern, got %s \n", w.Body.String())
+	}
+}
+
+func TestHandlerWithStatusCode(t *testing.T) {
+	failing := func(severity uint8) Check {
+		return Check{ID: "failing", Severity: severity, Checker: func() (string, error) { return "", errors.New("Failure") }}
+	}
+	passing := Check{ID: "passing", Severity: 1, Checker: func() (string, error) { return "", nil }}
+	statusCode := WithStatusCode(StatusBySeverity(map[uint8]int{1: http.StatusServiceUnavailable}))
+
+	tests := [...]struct {
+		name   string
+		checks []Check
+		opts   []HandlerOption
+		code   int
+	}{
+		{name: "Default is always 200", checks: []Check{failing(1)}, code: http.StatusOK},
+		{name: "Critical failure", checks: []Check{failing(1), passing}, opts: []HandlerOption{statusCode}, code: http.StatusServiceUnavailable},
+		{name: "Warning", checks: []Check{failing(2), passing}, opts: []HandlerOption{statusCode}, code: http.StatusOK},
+		{name: "Healthy", checks: []Check{passing}, opts: []HandlerOption{statusCode}, code: http.StatusOK},
+	}
+	for _, test := range tests {
+		for _, accept := range []string{"application/json", "text/html"} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/__health", nil)
+			r.Header.Set("Accept", accept)
+			Handler(HealthCheck{Checks: test.checks}, test.opts...)(w, r)
+			if w.Code != test.code {
+				t.Errorf("%s (%s): expected status %d, got %d \n", test.name, accept, test.code, w.Code)
+			}
+		}
+	}
+}
+
+func TestAckHandler(t *testing.T) {
+	clock := NewFakeClock(time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC))
+	checks := []Check{
+		{ID: "neo4j", Severity: 1, Checker: func() (string, error) { return "", errors.New("Failure") }},
+		{ID: "kafka", Severity: 2, Checker: func() (string, error) { return "", errors.New("Failure") }},
+	}
+	ah := NewAckHealthCheck(NewClockHealthCheck(HealthCheck{Checks: checks}, clock))
+	hc := NewPolicyHealthCheck(ah, AckPolicy{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/__health/{checkID}/ack", AckHandler(ah))
+	request := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := request(http.MethodPut, "/__health/neo4j/ack", `{"author": "ops"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an acknowledgement without a message to be rejected, got %d \n", w.Code)
+	}
+	w := request(http.MethodPut, "/__health/neo4j/ack", `{"message": "Cluster is being upgraded", "author": "ops", "expires": "2026-10-18T10:00:00Z"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected the check to be acknowledged, got %d %s \n", w.Code, w.Body.String())
+	}
+
+	result := RunCheck(hc)
+	const ack = "Cluster is being upgraded - ops, until 2026-10-18T10:00:00Z"
+	if result.Checks[0].Ack != ack || result.Checks[1].Ack != "" {
+		t.Errorf("Expected only neo4j to be acknowledged, got %q and %q \n", result.Checks[0].Ack, result.Checks[1].Ack)
+	}
+	if result.Ok || result.Severity != 2 {
+		t.Errorf("Expected the acknowledged check to be left out of the severity, got ok %v severity %d \n", result.Ok, result.Severity)
+	}
+	if result := RunCheck(ah); result.Severity != 1 {
+		t.Errorf("Expected the acknowledged check to count without AckPolicy, got severity %d \n", result.Severity)
+	}
+
+	clock.Advance(time.Hour)
+	if result := RunCheck(hc); result.Checks[0].Ack != "" || result.Severity != 1 {
+		t.Errorf("Expected the acknowledgement to expire, got %q with severity %d \n", result.Checks[0].Ack, result.Severity)
+	}
+	if w := request(http.MethodGet, "/__health/neo4j/ack", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected no acknowledgement after it expired, got %d \n", w.Code)
+	}
+
+	request(http.MethodPost, "/__health/kafka/ack", `{"message": "Known issue"}`)
+	if w := request(http.MethodGet, "/__health/kafka/ack", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Known issue") {
+		t.Errorf("Expected the acknowledgement of kafka, got %d %s \n", w.Code, w.Body.String())
+	}
+	if w := request(http.MethodDelete, "/__health/kafka/ack", ""); w.Code != http.StatusNoContent {
+		t.Errorf("Expected the acknowledgement to be cleared, got %d \n", w.Code)
+	}
+	if w := request(http.MethodDelete, "/__health/kafka/ack", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 clearing a check which is not acknowledged, got %d \n", w.Code)
+	}
+	if result := RunCheck(hc); result.Checks[1].Ack != "" {
+		t.Errorf("Expected no acknowledgements, got %q \n", result.Checks[1].Ack)
+	}
+}
@@ -0,0 +1,111 @@
+package v1_1
+
+import (
+	"context"
+	"sync"
+)
+
+// ObservedHealthCheck hands the result of every run to its subscribers, as it is returned by
+// RunCheckContext with the policy of the outermost HC applied. Runs narrowed down by a filter, gate
+// or probe are not handed on. Subscribers never block a run: slow
+// callbacks only get the latest result once they are done, and full channels miss results.
+type ObservedHealthCheck struct {
+	HC
+
+	mu          sync.Mutex
+	subscribers map[int]func(HealthResult)
+	next        int
+}
+
+func NewObservedHealthCheck(hc HC) *ObservedHealthCheck {
+	return &ObservedHealthCheck{HC: hc, subscribers: make(map[int]func(HealthResult))}
+}
+
+// Subscribe calls fn with the result of each run from its own goroutine. Results arriving while fn
+// is still busy are coalesced, so fn is next called with the latest of them.
+func (o *ObservedHealthCheck) Subscribe(fn func(HealthResult)) (unsubscribe func()) {
+	var (
+		mu      sync.Mutex
+		pending *HealthResult
+	)
+	wake := make(chan struct{}, 1)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-wake:
+				mu.Lock()
+				result := pending
+				pending = nil
+				mu.Unlock()
+				if result != nil {
+					fn(*result)
+				}
+			}
+		}
+	}()
+
+	remove := o.add(func(result HealthResult) {
+		mu.Lock()
+		pending = &result
+		mu.Unlock()
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			remove()
+			close(done)
+		})
+	}
+}
+
+// SubscribeChan sends the result of each run to ch, dropping it when ch is not ready to receive.
+// Give ch a buffer to avoid missing results.
+func (o *ObservedHealthCheck) SubscribeChan(ch chan<- HealthResult) (unsubscribe func()) {
+	return o.add(func(result HealthResult) {
+		select {
+		case ch <- result:
+		default:
+		}
+	})
+}
+
+func (o *ObservedHealthCheck) add(notify func(HealthResult)) func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	id := o.next
+	o.next++
+	o.subscribers[id] = notify
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		delete(o.subscribers, id)
+	}
+}
+
+func (o *ObservedHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	o.HC.doChecks(ctx, result)
+	// A run of some of the checks is not the status of the service
+	if isFiltered(ctx) {
+		return
+	}
+	onRunCompleted(ctx, o.HC, result, o.notify)
+}
+
+func (o *ObservedHealthCheck) notify(result *HealthResult) {
+	// Subscribers get their own copy of the checks, which the caller of the run may still change
+	observed := *result
+	observed.Checks = append([]CheckResult(nil), result.Checks...)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, notify := range o.subscribers {
+		notify(observed)
+	}
+}
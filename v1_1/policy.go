@@ -0,0 +1,82 @@
+package v1_1
+
+// Policy works out the overall status and severity of a health check run from its check results.
+// Severity is only asked for when Status is false.
+type Policy interface {
+	Status(result *HealthResult) bool
+	Severity(result *HealthResult) uint8
+}
+
+// PolicyHealthCheck uses its Policy for the overall status and severity instead of DefaultPolicy
+type PolicyHealthCheck struct {
+	HC
+	Policy Policy
+}
+
+func NewPolicyHealthCheck(hc HC, policy Policy) PolicyHealthCheck {
+	return PolicyHealthCheck{hc, policy}
+}
+
+func (ph PolicyHealthCheck) policy() Policy {
+	return ph.Policy
+}
+
+// DefaultPolicy fails when any check fails, with the severity of the most severe failure
+type DefaultPolicy struct{}
+
+func (DefaultPolicy) Status(result *HealthResult) bool {
+	return ComputeOverallStatus(result)
+}
+
+func (DefaultPolicy) Severity(result *HealthResult) uint8 {
+	return ComputeOverallSeverity(result)
+}
+
+// SeverityPolicy only fails for failed checks with a severity up to MaxSeverity, e.g. a MaxSeverity
+// of 2 ignores severity 3 failures.
+type SeverityPolicy struct {
+	MaxSeverity uint8
+}
+
+func (sp SeverityPolicy) Status(result *HealthResult) bool {
+	for _, check := range result.Checks {
+		if check.failed() && check.Severity <= sp.MaxSeverity {
+			return false
+		}
+	}
+	return true
+}
+
+func (sp SeverityPolicy) Severity(result *HealthResult) uint8 {
+	return ComputeOverallSeverity(result)
+}
+
+// WeightedPolicy fails when the failed checks make up at least Threshold (between 0 and 1) of the
+// total weight of the checks. Weights are looked up by check ID and default to 1, so with no
+// Weights set Threshold is the fraction of checks which have to fail.
+type WeightedPolicy struct {
+	Weights   map[string]float64
+	Threshold float64
+}
+
+func (wp WeightedPolicy) Status(result *HealthResult) bool {
+	var failed, total float64
+	for _, check := range result.Checks {
+		if check.Skipped || check.InGracePeriod {
+			continue
+		}
+		weight, ok := wp.Weights[check.ID]
+		if !ok {
+			weight = 1
+		}
+		total += weight
+		if check.failed() {
+			failed += weight
+		}
+	}
+	return failed == 0 || failed < wp.Threshold*total
+}
+
+func (wp WeightedPolicy) Severity(result *HealthResult) uint8 {
+	return ComputeOverallSeverity(result)
+}
@@ -0,0 +1,189 @@
+package v1_1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TagLiveness marks the checks run by LivenessHandler by default
+const TagLiveness = "liveness"
+
+const defaultProbeTimeout = 10 * time.Second
+
+// ProbeConfig configures a Kubernetes probe handler
+type ProbeConfig struct {
+	// Select picks the checks run by the probe, see the handlers for their defaults
+	Select func(Check) bool
+	// Timeout fails the probe when the checks have not finished by then. It defaults to 10 seconds,
+	// which is meant to catch deadlocks rather than slow dependencies.
+	Timeout time.Duration
+	// Options are applied to the handler as they are for Handler, e.g. WithCoalescing
+	Options []HandlerOption
+}
+
+// LivenessHandler serves a Kubernetes liveness probe (livez). It only runs the checks tagged with
+// TagLiveness unless Select is set, so dependency outages do not get the service restarted, and
+// fails when the run does not finish within the timeout.
+func LivenessHandler(hc HC, config ProbeConfig) func(w http.ResponseWriter, r *http.Request) {
+	if config.Select == nil {
+		config.Select = func(check Check) bool {
+			return check.hasAnyTag([]string{TagLiveness})
+		}
+	}
+	return newProbe(hc, "livez", config).handle
+}
+
+// ReadinessHandler serves a Kubernetes readiness probe (readyz), which runs every check unless
+// Select is set and fails when the HC policy does.
+func ReadinessHandler(hc HC, config ProbeConfig) func(w http.ResponseWriter, r *http.Request) {
+	return newProbe(hc, "readyz", config).handle
+}
+
+// StartupHandler serves a Kubernetes startup probe, which passes once each check, every one
+// unless Select is set, has passed at least once. It keeps passing after that without running
+// the checks again, which requests excluding checks are not taken as evidence for.
+func StartupHandler(hc HC, config ProbeConfig) func(w http.ResponseWriter, r *http.Request) {
+	p := newProbe(hc, "startupz", config)
+	p.startup = &startupLatch{passed: make(map[string]bool)}
+	return p.handle
+}
+
+type probe struct {
+	ch      *checkHandler
+	name    string
+	config  ProbeConfig
+	startup *startupLatch
+}
+
+// startupLatch remembers which checks have passed once
+type startupLatch struct {
+	mu     sync.Mutex
+	passed map[string]bool
+	done   bool
+}
+
+func newProbe(hc HC, name string, config ProbeConfig) *probe {
+	if config.Timeout == 0 {
+		config.Timeout = defaultProbeTimeout
+	}
+	return &probe{ch: newCheckHandler(hc, config.Options), name: name, config: config}
+}
+
+// handle follows the conventions of the kube-apiserver probe endpoints: ?verbose lists every check,
+// and ?exclude=<check ID>, which can be repeated, leaves checks out.
+func (p *probe) handle(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	_, verbose := query["verbose"]
+	excluded := splitQuery(query["exclude"])
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if p.startup.isDone() {
+		p.write(w, true, verbose, nil, nil, excluded)
+		return
+	}
+
+	ctx := r.Context()
+	if p.config.Select != nil {
+		ctx = withSelector(ctx, p.config.Select)
+	}
+	key := ""
+	if len(excluded) != 0 {
+		ctx = withSelector(ctx, func(check Check) bool {
+			return !GateByID(excluded...)(check)
+		})
+		key = strings.Join(excluded, ",")
+	}
+
+	results := make(chan HealthResult, 1)
+	go func() {
+		if health, err := p.ch.runCheck(ctx, key); err == nil {
+			results <- health
+		}
+	}()
+	var health HealthResult
+	clock := p.ch.clock()
+	timeout := clock.After(p.config.Timeout)
+	select {
+	case health = <-results:
+		stopTimer(clock, timeout)
+	case <-timeout:
+		p.write(w, false, verbose, nil, []string{fmt.Sprintf("[-]checks did not finish within %v", p.config.Timeout)}, excluded)
+		return
+	case <-r.Context().Done():
+		// The probe was abandoned, so there is no one to respond to
+		stopTimer(clock, timeout)
+		return
+	}
+
+	// The startup probe passes once each check has passed, rather than following the policy
+	ok := health.Ok || p.startup != nil
+	var lines []string
+	for i, check := range health.Checks {
+		name := resultKey(i, check)
+		if p.startup != nil {
+			// Checks without an ID are known by their position, which excluding checks changes
+			if len(excluded) == 0 || check.ID != "" {
+				check.Ok = p.startup.pass(name, check.Ok)
+			}
+			ok = ok && check.Ok
+		}
+		if check.Ok || !check.failed() {
+			lines = append(lines, fmt.Sprintf("[+]%s ok", name))
+		} else {
+			lines = append(lines, fmt.Sprintf("[-]%s failed: reason withheld", name))
+		}
+	}
+	// Only a run of every selected check shows that everything has succeeded once
+	if p.startup != nil && ok && len(excluded) == 0 {
+		p.startup.latch()
+	}
+	p.write(w, ok, verbose, lines, nil, excluded)
+}
+
+func (p *probe) write(w http.ResponseWriter, ok, verbose bool, lines, problems, excluded []string) {
+	if ok && !verbose {
+		w.Write([]byte("ok"))
+		return
+	}
+	for _, id := range excluded {
+		lines = append(lines, fmt.Sprintf("[+]%s excluded: ok", id))
+	}
+	lines = append(lines, problems...)
+	if ok {
+		lines = append(lines, p.name+" check passed")
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+		lines = append(lines, p.name+" check failed")
+	}
+	w.Write([]byte(strings.Join(lines, "\n") + "\n"))
+}
+
+func (sl *startupLatch) isDone() bool {
+	if sl == nil {
+		return false
+	}
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	return sl.done
+}
+
+// pass records the latest status of a check, returning whether it has passed at least once
+func (sl *startupLatch) pass(key string, ok bool) bool {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	if ok {
+		sl.passed[key] = true
+	}
+	return sl.passed[key]
+}
+
+// latch makes the probe pass from now on
+func (sl *startupLatch) latch() {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+	sl.done = true
+}
@@ -0,0 +1,104 @@
+package v1_1
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Registry is an HC whose checks can be registered, unregistered and replaced by their ID while
+// it is in use. Its checks are run in parallel, and each run uses the checks registered when it
+// started.
+type Registry struct {
+	SystemCode  string
+	Name        string
+	Description string
+
+	mu     sync.RWMutex
+	checks []Check
+}
+
+func NewRegistry(systemCode, name, description string) *Registry {
+	return &Registry{SystemCode: systemCode, Name: name, Description: description}
+}
+
+// Register adds check, which must have an ID that is not registered yet
+func (r *Registry) Register(check Check) error {
+	if check.ID == "" {
+		return fmt.Errorf("Cannot register check %q without an ID", check.Name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.find(check.ID) != -1 {
+		return fmt.Errorf("A check with ID %s is already registered", check.ID)
+	}
+	r.checks = append(r.checks[:len(r.checks):len(r.checks)], check)
+	return nil
+}
+
+// Replace swaps the registered check with the same ID as check for check
+func (r *Registry) Replace(check Check) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.find(check.ID)
+	if i == -1 {
+		return fmt.Errorf("No check with ID %s is registered", check.ID)
+	}
+	checks := make([]Check, len(r.checks))
+	copy(checks, r.checks)
+	checks[i] = check
+	r.checks = checks
+	return nil
+}
+
+// Unregister removes the check with the given ID, returning whether there was one
+func (r *Registry) Unregister(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.find(id)
+	if i == -1 {
+		return false
+	}
+	checks := make([]Check, 0, len(r.checks)-1)
+	checks = append(checks, r.checks[:i]...)
+	r.checks = append(checks, r.checks[i+1:]...)
+	return true
+}
+
+// Checks returns a copy of the checks registered at the moment, use Replace to change one of them
+func (r *Registry) Checks() []Check {
+	return append([]Check(nil), r.snapshot().Checks...)
+}
+
+func (r *Registry) find(id string) int {
+	for i, check := range r.checks {
+		if check.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// snapshot returns the registered checks as a HealthCheck. The slice of checks is never changed
+// once it has been registered, only replaced, so it can be shared with runs in progress.
+func (r *Registry) snapshot() HealthCheck {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return HealthCheck{r.SystemCode, r.Name, r.Description, r.checks}
+}
+
+func (r *Registry) initResult(result *HealthResult) {
+	r.snapshot().initResult(result)
+}
+
+func (r *Registry) doChecks(ctx context.Context, result *HealthResult) {
+	r.snapshot().doChecks(ctx, result)
+}
+
+func (r *Registry) policy() Policy {
+	return DefaultPolicy{}
+}
+
+func (r *Registry) clock() Clock {
+	return systemClock{}
+}
@@ -5,17 +5,33 @@ import (
 )
 
 type CheckResult struct {
-	ID               string    `json:"id"`
-	Name             string    `json:"name"`
-	Ok               bool      `json:"ok"`
-	Severity         uint8     `json:"severity"`
-	BusinessImpact   string    `json:"businessImpact"`
-	TechnicalSummary string    `json:"technicalSummary"`
-	PanicGuide       string    `json:"panicGuide"`
-	CheckOutput      string    `json:"checkOutput"`
-	LastUpdated      time.Time `json:"lastUpdated"`
-	Ack              string    `json:"ack,omitempty"`
-	PanicGuideIsLink bool      `json:"-"`
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	Ok               bool       `json:"ok"`
+	Severity         uint8      `json:"severity"`
+	BusinessImpact   string     `json:"businessImpact"`
+	TechnicalSummary string     `json:"technicalSummary"`
+	PanicGuide       string     `json:"panicGuide"`
+	CheckOutput      string     `json:"checkOutput"`
+	LastUpdated      time.Time  `json:"lastUpdated"`
+	Ack              string     `json:"ack,omitempty"`
+	Attempts         int        `json:"attempts,omitempty"`
+	PreviousErrors   []string   `json:"previousErrors,omitempty"`
+	Skipped          bool       `json:"skipped,omitempty"`
+	InGracePeriod    bool       `json:"inGracePeriod,omitempty"`
+	CircuitState     string     `json:"circuitState,omitempty"`
+	Since            *time.Time `json:"since,omitempty"`
+	PreviousOk       *bool      `json:"previousOk,omitempty"`
+	PanicGuideIsLink bool       `json:"-"`
+	// PanicStack is the stack trace of a panic in the checker, it is left out of the JSON output
+	PanicStack string `json:"-"`
+	// StartedAt, Duration and Timeout are only in the JSON output of handlers using WithTimings
+	StartedAt time.Time     `json:"-"`
+	Duration  time.Duration `json:"-"`
+	Timeout   time.Duration `json:"-"`
+	// run numbers the results of the scheduled runs of a check from 1, so that a new run can be told
+	// apart from the same result read again. It is 0 for checks run on each request.
+	run uint64
 }
 
 type HealthResult struct {
@@ -26,23 +42,36 @@ type HealthResult struct {
 	Checks        []CheckResult `json:"checks"`
 	Ok            bool          `json:"ok"`
 	Severity      uint8         `json:"severity,omitempty"`
+	Filter        *CheckFilter  `json:"filter,omitempty"`
+	// StartedAt and Duration are only in the JSON output of handlers using WithTimings
+	StartedAt time.Time     `json:"-"`
+	Duration  time.Duration `json:"-"`
 }
 
+// ComputeOverallStatus is false when any check failed. Skipped checks and checks in their startup
+// grace period are not counted.
 func ComputeOverallStatus(result *HealthResult) bool {
 	for _, check := range result.Checks {
-		if !check.Ok {
+		if check.failed() {
 			return false
 		}
 	}
 	return true
 }
 
+// ComputeOverallSeverity is the most severe of the failed checks, not counting the same checks as
+// ComputeOverallStatus.
 func ComputeOverallSeverity(result *HealthResult) uint8 {
 	var severity uint8 = 3
 	for _, check := range result.Checks {
-		if check.Ok == false && check.Severity < severity {
+		if check.failed() && check.Severity < severity {
 			severity = check.Severity
 		}
 	}
 	return severity
 }
+
+// failed is true when the check counts as a failure towards the overall result
+func (check CheckResult) failed() bool {
+	return !check.Ok && !check.Skipped && !check.InGracePeriod
+}
@@ -0,0 +1,72 @@
+package v1_1
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// RetryPolicy makes a failing check try again before it is reported as failed. All the attempts
+// share the check's Timeout.
+type RetryPolicy struct {
+	// MaxAttempts is the most times the checker is called, including the first call
+	MaxAttempts int
+	// Backoff is the wait before the second attempt, it doubles for every attempt after that
+	Backoff time.Duration
+	// MaxBackoff caps the wait between attempts when it is set
+	MaxBackoff time.Duration
+	// Jitter randomly varies each wait by up to this fraction of it, e.g. 0.2 for +/-20%
+	Jitter float64
+	// RetryIf decides which errors are worth retrying, all of them are when it is nil
+	RetryIf func(err error) bool
+}
+
+func (rp *RetryPolicy) shouldRetry(attempt int, err error) bool {
+	if attempt >= rp.MaxAttempts {
+		return false
+	}
+	return rp.RetryIf == nil || rp.RetryIf(err)
+}
+
+// backoff is the wait after the given attempt failed
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	wait := rp.Backoff
+	for i := 1; i < attempt && (rp.MaxBackoff == 0 || wait < rp.MaxBackoff); i++ {
+		wait *= 2
+	}
+	if rp.MaxBackoff != 0 && wait > rp.MaxBackoff {
+		wait = rp.MaxBackoff
+	}
+	if rp.Jitter != 0 {
+		wait += time.Duration(float64(wait) * rp.Jitter * (2*rand.Float64() - 1))
+	}
+	return wait
+}
+
+// attemptLog records the attempts made by a check, it is read once the check has returned or
+// timed out while the attempts may still be running.
+type attemptLog struct {
+	mu       sync.Mutex
+	attempts int
+	errors   []string
+}
+
+func (l *attemptLog) started() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts++
+	return l.attempts
+}
+
+func (l *attemptLog) retried(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, err.Error())
+}
+
+func (l *attemptLog) writeTo(result *CheckResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	result.Attempts = l.attempts
+	result.PreviousErrors = append([]string(nil), l.errors...)
+}
@@ -0,0 +1,128 @@
+package v1_1
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultScheduleInterval = 30 * time.Second
+
+// ScheduledHealthCheck runs each check in the background on its own interval and serves the latest
+// results, so requests to the health endpoint do not hit the dependencies themselves.
+type ScheduledHealthCheck struct {
+	HealthCheck
+	// Interval is used for checks which do not set Check.Interval
+	Interval time.Duration
+	// Clock schedules the checks, the system clock is used when it is nil
+	Clock Clock
+
+	mu      sync.RWMutex
+	index   map[string]int
+	results []CheckResult
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func NewScheduledHealthCheck(hc HealthCheck, interval time.Duration) *ScheduledHealthCheck {
+	return &ScheduledHealthCheck{HealthCheck: hc, Interval: interval}
+}
+
+// Start begins running the checks in the background, calling it on a started HC does nothing.
+// Until a check has run for the first time it is reported as failing.
+func (s *ScheduledHealthCheck) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	var ctx context.Context
+	ctx, s.cancel = context.WithCancel(withClock(context.Background(), s.clock()))
+	s.index = indexChecks(s.Checks)
+	s.results = make([]CheckResult, len(s.Checks))
+	for i := range s.Checks {
+		s.results[i] = s.Checks[i].newResult()
+		s.results[i].CheckOutput = "Check has not run yet"
+		s.wg.Add(1)
+		go s.schedule(ctx, i)
+	}
+}
+
+// Stop stops the background checks and waits for the running ones to return.
+func (s *ScheduledHealthCheck) Stop() {
+	s.mu.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		s.wg.Wait()
+	}
+}
+
+func (s *ScheduledHealthCheck) schedule(ctx context.Context, i int) {
+	defer s.wg.Done()
+	check := s.Checks[i]
+	interval := check.Interval
+	if interval <= 0 {
+		interval = s.Interval
+	}
+	if interval <= 0 {
+		interval = defaultScheduleInterval
+	}
+	for run := uint64(1); ; run++ {
+		var result CheckResult
+		if dep := blockingDependency(check, s.latest); dep != "" {
+			result = check.skippedResult(ctx, "depends on "+dep)
+		} else {
+			result = check.runChecker(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		result.run = run
+		s.mu.Lock()
+		s.results[i] = result
+		s.mu.Unlock()
+		next := s.clock().After(interval)
+		select {
+		case <-ctx.Done():
+			stopTimer(s.clock(), next)
+			return
+		case <-next:
+		}
+	}
+}
+
+func (s *ScheduledHealthCheck) clock() Clock {
+	if s.Clock == nil {
+		return systemClock{}
+	}
+	return s.Clock
+}
+
+// latest returns the most recent result of the check with the given ID
+func (s *ScheduledHealthCheck) latest(id string) (CheckResult, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i, ok := s.index[id]
+	if !ok {
+		return CheckResult{}, false
+	}
+	return s.results[i], true
+}
+
+func (s *ScheduledHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	selected := selectChecks(ctx, s.Checks)
+	result.Checks = make([]CheckResult, len(selected))
+	for i, j := range selected {
+		if s.results == nil {
+			result.Checks[i] = s.Checks[j].newResult()
+			result.Checks[i].CheckOutput = "Scheduled checks have not been started"
+		} else {
+			result.Checks[i] = s.results[j]
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package v1_1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// CheckPathValue is the name of the path wildcard SingleCheckHandler takes the check ID from
+const CheckPathValue = "checkID"
+
+// SingleCheckHandler runs only the check whose ID is given in the path and serves its CheckResult,
+// or 404 when there is no such check. Register it with a ServeMux pattern such as
+// "/__health/{checkID}"; without that wildcard the last segment of the path is used as the ID.
+func SingleCheckHandler(hc HC, opts ...HandlerOption) func(w http.ResponseWriter, r *http.Request) {
+	ch := newCheckHandler(hc, opts)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := checkIDFrom(r)
+		ctx := withSelector(r.Context(), GateByID(id))
+		health, err := ch.runCheck(ctx, "id:"+id)
+		if err != nil {
+			return
+		}
+
+		for _, check := range health.Checks {
+			if check.ID == id {
+				ch.writeCheck(w, r, health, check)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		msg, _ := json.Marshal(ErrorMessage{fmt.Sprintf("No check with ID %q", id)})
+		w.Write(msg)
+	}
+}
+
+// checkIDFrom takes the check ID from the CheckPathValue wildcard, or the last segment of the path
+func checkIDFrom(r *http.Request) string {
+	if id := r.PathValue(CheckPathValue); id != "" {
+		return id
+	}
+	return path.Base(r.URL.Path)
+}
+
+func (ch *checkHandler) writeCheck(w http.ResponseWriter, r *http.Request, health HealthResult, check CheckResult) {
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		health.Checks = []CheckResult{check}
+		err := writeHTMLResp(w, ch.status(health), htmlView{health, ch.timings})
+		if err == nil {
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	var body interface{} = check
+	if ch.timings {
+		body = timedCheckResult{check, check.StartedAt, milliseconds(check.Duration), milliseconds(check.Timeout)}
+	}
+	resp, err := json.Marshal(body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		msg, _ := json.Marshal(ErrorMessage{fmt.Sprintf("Failed to encode result of check %s, error was: %v", check.ID, err)})
+		w.Write(msg)
+		return
+	}
+	w.WriteHeader(ch.status(health))
+	w.Write(append(resp, '\n'))
+}
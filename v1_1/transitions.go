@@ -0,0 +1,111 @@
+package v1_1
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// StatusChange describes a check, or the service overall, changing between ok and not ok
+type StatusChange struct {
+	// Overall is set for a change of the overall status. Previous and Current then summarise the
+	// service, with its system code as ID.
+	Overall  bool
+	Previous CheckResult
+	Current  CheckResult
+	// At is when the change was seen, which for a check is when it ran
+	At time.Time
+	// Lasted is how long the previous status lasted
+	Lasted time.Duration
+}
+
+// TransitionHealthCheck reports status changes to OnChange, and sets Since and PreviousOk on the
+// check results. Changes of the overall status follow the policy of the outermost HC, and are only
+// looked for in runs of every check, not ones narrowed down by a filter, gate or probe. OnChange is
+// called during the run which saw the change, so it has to return quickly.
+type TransitionHealthCheck struct {
+	HC
+	OnChange func(change StatusChange)
+
+	mu      sync.Mutex
+	checks  map[string]*statusState
+	overall *statusState
+}
+
+type statusState struct {
+	last       CheckResult
+	since      time.Time
+	previousOk *bool
+}
+
+func NewTransitionHealthCheck(hc HC, onChange func(change StatusChange)) *TransitionHealthCheck {
+	return &TransitionHealthCheck{HC: hc, OnChange: onChange, checks: make(map[string]*statusState)}
+}
+
+func (th *TransitionHealthCheck) doChecks(ctx context.Context, result *HealthResult) {
+	th.HC.doChecks(ctx, result)
+	clock := clockFrom(ctx)
+	// A run of some of the checks says nothing about the overall status of the service
+	overall := !isFiltered(ctx)
+	onRunCompleted(ctx, th.HC, result, func(result *HealthResult) {
+		th.record(result, clock.Now(), overall)
+	})
+}
+
+// record compares the final result of a run with the previous ones, now being when it completed.
+// The overall status is only compared when overall is set.
+func (th *TransitionHealthCheck) record(result *HealthResult, now time.Time, overall bool) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	var changes []StatusChange
+	for i := range result.Checks {
+		check := &result.Checks[i]
+		// Checks without an ID are known by their position, which only holds in runs of every check
+		if check.Skipped || (!overall && check.ID == "") {
+			continue
+		}
+		key := resultKey(i, *check)
+		state, ok := th.checks[key]
+		if !ok {
+			state = &statusState{last: *check, since: check.LastUpdated}
+			th.checks[key] = state
+		}
+		if change, changed := state.update(*check, check.LastUpdated); changed {
+			changes = append(changes, change)
+		}
+		since := state.since
+		check.Since = &since
+		check.PreviousOk = state.previousOk
+	}
+
+	if overall {
+		summary := CheckResult{ID: result.SystemCode, Name: result.Name, Ok: result.Ok, Severity: result.Severity, LastUpdated: now}
+		if th.overall == nil {
+			th.overall = &statusState{last: summary, since: summary.LastUpdated}
+		}
+		if change, changed := th.overall.update(summary, summary.LastUpdated); changed {
+			change.Overall = true
+			changes = append(changes, change)
+		}
+	}
+
+	if th.OnChange != nil {
+		for _, change := range changes {
+			th.OnChange(change)
+		}
+	}
+}
+
+// update records the latest result seen at the given time, returning the change when its status changed
+func (s *statusState) update(current CheckResult, at time.Time) (StatusChange, bool) {
+	previous := s.last
+	s.last = current
+	if previous.Ok == current.Ok {
+		return StatusChange{}, false
+	}
+	change := StatusChange{Previous: previous, Current: current, At: at, Lasted: at.Sub(s.since)}
+	wasOk := previous.Ok
+	s.previousOk = &wasOk
+	s.since = at
+	return change, true
+}